@@ -0,0 +1,170 @@
+package se2
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const defaultBatchConcurrency = 8
+
+// ErrBatchAborted is the error of the inputs that were not run because an earlier one failed in fail-fast mode.
+var ErrBatchAborted = errors.New("batch aborted before this input was run")
+
+// ExecTarget identifies a plugin by the ident, namespace, and plugin triad that Exec takes.
+type ExecTarget struct {
+	Ident     string
+	Namespace string
+	Plugin    string
+}
+
+// BatchOptions configures ExecBatch.
+type BatchOptions struct {
+	// Concurrency is how many executions run at the same time. Defaults to 8.
+	Concurrency int
+
+	// FailFast stops the batch at the first failed input. The inputs that were not run yet get ErrBatchAborted as
+	// their error, and the ones in flight are cancelled. By default, every input is run regardless of failures.
+	FailFast bool
+
+	// OnProgress, if set, is called after every input is done. Calls are not concurrent.
+	OnProgress func(BatchProgress)
+
+	// ExecOptions are applied to every execution.
+	ExecOptions []ExecOption
+}
+
+// BatchProgress is passed to the OnProgress callback of a batch.
+type BatchProgress struct {
+	// Index is the position of the input that just finished, and Err its error, if any.
+	Index int
+	Err   error
+
+	// Done is how many inputs have finished, Failed how many of those failed, and Total the size of the batch.
+	Done   int
+	Failed int
+	Total  int
+}
+
+// BatchResult is the outcome of a single input of a batch.
+type BatchResult struct {
+	Output []byte
+	Err    error
+}
+
+// BatchError is returned by ExecBatch when some inputs failed and the batch was not in fail-fast mode. The individual
+// errors are on the results.
+type BatchError struct {
+	Failed int
+	Total  int
+}
+
+// Error implements the error interface.
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d of %d batch inputs failed", e.Failed, e.Total)
+}
+
+// ExecBatch runs the plugin once for each of the inputs with Exec, a bounded number at a time, and returns the results
+// in the order of the inputs.
+//
+// In fail-fast mode, the error is that of the first input to fail. Otherwise, every input is run, and the error is a
+// *BatchError if any of them failed. Either way the results hold the outcome of every input.
+func (c *Client) ExecBatch(ctx context.Context, target ExecTarget, inputs [][]byte, opts BatchOptions) (_ []BatchResult, err error) {
+	ctx, span := c.startSpan(ctx, Operation{Name: "client.ExecBatch", Tenant: target.Ident, Namespace: target.Namespace, Plugin: target.Plugin})
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	span.SetAttributes(attribute.Int("se2.batch_size", len(inputs)))
+
+	results := make([]BatchResult, len(inputs))
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	if concurrency > len(inputs) {
+		concurrency = len(inputs)
+	}
+
+	batchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		progress = BatchProgress{Total: len(inputs)}
+		firstErr error
+	)
+
+	// finish records the outcome of an input, and reports progress.
+	finish := func(i int, output []byte, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		results[i] = BatchResult{Output: output, Err: err}
+
+		progress.Index = i
+		progress.Err = err
+		progress.Done++
+
+		if err != nil {
+			progress.Failed++
+
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "input %d", i)
+			}
+
+			if opts.FailFast {
+				cancel()
+			}
+		}
+
+		if opts.OnProgress != nil {
+			opts.OnProgress(progress)
+		}
+	}
+
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				if opts.FailFast && batchCtx.Err() != nil {
+					finish(i, nil, ErrBatchAborted)
+
+					continue
+				}
+
+				output, err := c.Exec(batchCtx, inputs[i], target.Ident, target.Namespace, target.Plugin, opts.ExecOptions...)
+				finish(i, output, err)
+			}
+		}()
+	}
+
+	for i := range inputs {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	if progress.Failed == 0 {
+		return results, nil
+	}
+
+	if opts.FailFast {
+		return results, errors.Wrap(firstErr, "client.ExecBatch")
+	}
+
+	return results, &BatchError{Failed: progress.Failed, Total: progress.Total}
+}
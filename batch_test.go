@@ -0,0 +1,98 @@
+package se2_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+)
+
+func TestClient_ExecBatch(t *testing.T) {
+	var inFlight, maxInFlight int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+
+		for {
+			current := atomic.LoadInt32(&maxInFlight)
+			if n <= current || atomic.CompareAndSwapInt32(&maxInFlight, current, n) {
+				break
+			}
+		}
+
+		b, _ := io.ReadAll(r.Body)
+
+		time.Sleep(5 * time.Millisecond)
+
+		if string(b) == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(strings.ToUpper(string(b))))
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	target := se2.ExecTarget{Ident: "acme", Namespace: "default", Plugin: "upper"}
+
+	inputs := make([][]byte, 20)
+	for i := range inputs {
+		inputs[i] = []byte(fmt.Sprintf("input-%d", i))
+	}
+
+	inputs[3] = []byte("fail")
+
+	var calls int
+
+	results, err := client.ExecBatch(context.Background(), target, inputs, se2.BatchOptions{
+		Concurrency: 3,
+		OnProgress: func(p se2.BatchProgress) {
+			calls++
+
+			assert.Equal(t, 20, p.Total)
+			assert.Equal(t, calls, p.Done)
+		},
+	})
+
+	var batchErr *se2.BatchError
+	require.True(t, errors.As(err, &batchErr))
+	assert.Equal(t, 1, batchErr.Failed)
+
+	assert.Equal(t, 20, calls)
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(3))
+
+	for i, r := range results {
+		if i == 3 {
+			assert.ErrorIs(t, r.Err, se2.ErrServerError)
+
+			continue
+		}
+
+		assert.NoError(t, r.Err)
+		assert.Equal(t, fmt.Sprintf("INPUT-%d", i), string(r.Output))
+	}
+
+	results, err = client.ExecBatch(context.Background(), target, inputs, se2.BatchOptions{Concurrency: 1, FailFast: true})
+	assert.ErrorIs(t, err, se2.ErrServerError)
+
+	assert.Equal(t, []byte("INPUT-2"), results[2].Output)
+	assert.ErrorIs(t, results[3].Err, se2.ErrServerError)
+	assert.ErrorIs(t, results[4].Err, se2.ErrBatchAborted)
+	assert.ErrorIs(t, results[19].Err, se2.ErrBatchAborted)
+}
@@ -0,0 +1,347 @@
+package se2
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerOpenTimeout      = 30 * time.Second
+	defaultBreakerHalfOpenProbes   = 1
+	defaultBreakerMaxPlugins       = 1024
+)
+
+// ErrCircuitOpen is returned by Exec when the circuit breaker of the plugin is open, and there is no fallback.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of the circuit breaker of a plugin.
+type CircuitState int
+
+const (
+	// CircuitClosed lets every execution through. It's the state a breaker starts in.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen fails executions right away, or runs the fallback, without calling the plugin.
+	CircuitOpen
+
+	// CircuitHalfOpen lets a few probe executions through to find out whether the plugin recovered.
+	CircuitHalfOpen
+)
+
+// String returns the name of the state.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerPolicy configures the circuit breakers, see WithCircuitBreaker.
+type BreakerPolicy struct {
+	// Plugins are the plugins that get a circuit breaker. If empty, every plugin gets its own, and MaxPlugins limits how
+	// many breakers are kept: once there are more, the least recently used one is dropped, and its plugin starts with a
+	// closed circuit the next time it's run. MaxPlugins defaults to 1024.
+	Plugins    []ExecTarget
+	MaxPlugins int
+
+	// FailureThreshold is how many executions in a row need to fail to open the circuit. Defaults to 5.
+	FailureThreshold int
+
+	// OpenTimeout is how long the circuit stays open before it lets probes through. Defaults to 30 seconds.
+	OpenTimeout time.Duration
+
+	// HalfOpenProbes is how many probes can be in flight while the circuit is half-open, and how many of them need to
+	// succeed to close it again. A single failed probe opens it again. Defaults to 1.
+	HalfOpenProbes int
+
+	// IsFailure decides whether the error of an execution counts as a failure of the plugin. By default, only 5xx
+	// responses of the edge and errors of sending the request, like timeouts, do. 4xx responses, executions cancelled by
+	// the caller, and errors the client runs into before it sends the request, like an invalid plugin name, a payload
+	// over the limit, or a missing access key, do not.
+	IsFailure func(err error) bool
+
+	// OnStateChange, if set, is called whenever the circuit of a plugin changes state.
+	OnStateChange func(target ExecTarget, from, to CircuitState)
+
+	// Fallback, if set, is called instead of the plugin when its circuit is open, and its output and error are what Exec
+	// returns. The error passed to it matches ErrCircuitOpen.
+	Fallback func(ctx context.Context, target ExecTarget, payload []byte, err error) ([]byte, error)
+}
+
+// WithCircuitBreaker turns on circuit breakers for Exec, so a failing plugin fails fast instead of every call waiting
+// for it. After FailureThreshold failures in a row, the circuit of the plugin opens, and Exec returns ErrCircuitOpen, or
+// the output of the fallback, without calling the plugin. Once OpenTimeout passed, a few probe executions are let
+// through, and the circuit closes again if they succeed.
+func WithCircuitBreaker(policy BreakerPolicy) ClientOption {
+	return func(c *Client) {
+		c.breakers = newBreakers(policy)
+	}
+}
+
+// CircuitState returns the state of the circuit breaker of the plugin. It's CircuitClosed for plugins without one.
+func (c *Client) CircuitState(target ExecTarget) CircuitState {
+	b, ok := c.breakers.peek(target)
+	if !ok {
+		return CircuitClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// An open circuit whose timeout passed lets the next execution through as a probe.
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.policy.OpenTimeout {
+		return CircuitHalfOpen
+	}
+
+	return b.state
+}
+
+// breakers holds the circuit breakers of the plugins.
+type breakers struct {
+	policy BreakerPolicy
+	fixed  bool
+
+	mu       sync.Mutex
+	breakers map[ExecTarget]*breaker
+
+	// lru orders the breakers by when they were last used, if every plugin gets one.
+	lru *list.List
+}
+
+// newBreakers creates the breakers for the policy, filling in the defaults.
+func newBreakers(policy BreakerPolicy) *breakers {
+	if policy.FailureThreshold <= 0 {
+		policy.FailureThreshold = defaultBreakerFailureThreshold
+	}
+
+	if policy.OpenTimeout <= 0 {
+		policy.OpenTimeout = defaultBreakerOpenTimeout
+	}
+
+	if policy.HalfOpenProbes <= 0 {
+		policy.HalfOpenProbes = defaultBreakerHalfOpenProbes
+	}
+
+	if policy.MaxPlugins <= 0 {
+		policy.MaxPlugins = defaultBreakerMaxPlugins
+	}
+
+	if policy.IsFailure == nil {
+		policy.IsFailure = isPluginFailure
+	}
+
+	bs := &breakers{
+		policy:   policy,
+		fixed:    len(policy.Plugins) > 0,
+		breakers: make(map[ExecTarget]*breaker, len(policy.Plugins)),
+		lru:      list.New(),
+	}
+
+	for _, target := range policy.Plugins {
+		bs.breakers[target] = &breaker{target: target, policy: &bs.policy}
+	}
+
+	return bs
+}
+
+// get returns the breaker of the plugin, creating it if every plugin gets one, or false if the plugin has none. It's
+// safe to call on nil breakers.
+func (bs *breakers) get(target ExecTarget) (*breaker, bool) {
+	if bs == nil {
+		return nil, false
+	}
+
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	b, ok := bs.breakers[target]
+	if bs.fixed {
+		return b, ok
+	}
+
+	if ok {
+		bs.lru.MoveToFront(b.el)
+
+		return b, true
+	}
+
+	b = &breaker{target: target, policy: &bs.policy}
+	b.el = bs.lru.PushFront(b)
+	bs.breakers[target] = b
+
+	for bs.lru.Len() > bs.policy.MaxPlugins {
+		oldest := bs.lru.Remove(bs.lru.Back()).(*breaker)
+		delete(bs.breakers, oldest.target)
+	}
+
+	return b, true
+}
+
+// peek returns the breaker of the plugin without creating it, or marking it as used. It's safe to call on nil
+// breakers.
+func (bs *breakers) peek(target ExecTarget) (*breaker, bool) {
+	if bs == nil {
+		return nil, false
+	}
+
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	b, ok := bs.breakers[target]
+
+	return b, ok
+}
+
+// isPluginFailure is the default of BreakerPolicy.IsFailure.
+func isPluginFailure(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return errors.Is(apiErr, ErrServerError)
+	}
+
+	// A payload over the limit fails while it's being sent, but it's still not the plugin's fault.
+	var transportErr *transportError
+
+	return errors.As(err, &transportErr) && !errors.Is(err, context.Canceled) && !errors.Is(err, ErrPayloadTooLarge)
+}
+
+// breaker is the circuit breaker of a single plugin.
+type breaker struct {
+	target ExecTarget
+	policy *BreakerPolicy
+
+	// el is the element of the breaker in the LRU list, if every plugin gets one.
+	el *list.Element
+
+	mu        sync.Mutex
+	state     CircuitState
+	failures  int
+	openedAt  time.Time
+	probes    int
+	successes int
+}
+
+// transition is a state change to report to the callback once the lock is released.
+type transition struct {
+	from CircuitState
+	to   CircuitState
+}
+
+// allow reports whether an execution can go through, and moves an open circuit whose timeout passed to half-open.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+
+	var changes []transition
+
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.policy.OpenTimeout {
+		changes = append(changes, b.setState(CircuitHalfOpen))
+	}
+
+	allowed := true
+
+	switch b.state {
+	case CircuitOpen:
+		allowed = false
+	case CircuitHalfOpen:
+		allowed = b.probes < b.policy.HalfOpenProbes
+		if allowed {
+			b.probes++
+		}
+	case CircuitClosed:
+	}
+
+	b.mu.Unlock()
+	b.notify(changes)
+
+	return allowed
+}
+
+// record updates the breaker with the outcome of an execution that allow let through.
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+
+	var changes []transition
+
+	failed := err != nil && b.policy.IsFailure(err)
+	ignored := err != nil && !failed
+
+	switch b.state {
+	case CircuitClosed:
+		switch {
+		case failed:
+			b.failures++
+			if b.failures >= b.policy.FailureThreshold {
+				changes = append(changes, b.setState(CircuitOpen))
+			}
+		case !ignored:
+			b.failures = 0
+		}
+	case CircuitHalfOpen:
+		b.probes--
+
+		switch {
+		case failed:
+			changes = append(changes, b.setState(CircuitOpen))
+		case !ignored:
+			b.successes++
+			if b.successes >= b.policy.HalfOpenProbes {
+				changes = append(changes, b.setState(CircuitClosed))
+			}
+		}
+	case CircuitOpen:
+		// A probe that returned after another one opened the circuit again.
+	}
+
+	b.mu.Unlock()
+	b.notify(changes)
+}
+
+// setState moves the breaker to the state, resetting the counters. It needs to be called with the lock held.
+func (b *breaker) setState(state CircuitState) transition {
+	t := transition{from: b.state, to: state}
+
+	b.state = state
+	b.failures = 0
+	b.probes = 0
+	b.successes = 0
+
+	if state == CircuitOpen {
+		b.openedAt = time.Now()
+	}
+
+	return t
+}
+
+// notify calls the state change callback for the transitions.
+func (b *breaker) notify(changes []transition) {
+	if b.policy.OnStateChange == nil {
+		return
+	}
+
+	for _, t := range changes {
+		b.policy.OnStateChange(b.target, t.from, t.to)
+	}
+}
+
+// open returns the result of an execution that was not let through: the output of the fallback if there is one, or
+// ErrCircuitOpen.
+func (b *breaker) open(ctx context.Context, payload []byte) ([]byte, error) {
+	err := errors.Wrapf(ErrCircuitOpen, "client.Exec: %s/%s/%s", b.target.Ident, b.target.Namespace, b.target.Plugin)
+
+	if b.policy.Fallback == nil {
+		return nil, err
+	}
+
+	return b.policy.Fallback(ctx, b.target, payload, err)
+}
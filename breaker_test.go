@@ -0,0 +1,230 @@
+package se2_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+)
+
+func TestWithCircuitBreaker(t *testing.T) {
+	var (
+		healthy  atomic.Bool
+		requests int32
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`ok`))
+	}))
+	defer srv.Close()
+
+	var (
+		mu          sync.Mutex
+		transitions []string
+	)
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL, srv.URL),
+		se2.WithCircuitBreaker(se2.BreakerPolicy{
+			FailureThreshold: 2,
+			OpenTimeout:      20 * time.Millisecond,
+			OnStateChange: func(target se2.ExecTarget, from, to se2.CircuitState) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				transitions = append(transitions, target.Plugin+": "+from.String()+" -> "+to.String())
+			},
+		}),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, err = client.Exec(ctx, []byte(`hi`), "acme", "default", "pure")
+		assert.ErrorIs(t, err, se2.ErrServerError)
+	}
+
+	assert.Equal(t, se2.CircuitOpen, client.CircuitState(pure))
+
+	_, err = client.Exec(ctx, []byte(`hi`), "acme", "default", "pure")
+	assert.ErrorIs(t, err, se2.ErrCircuitOpen)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests), "the plugin is not called while the circuit is open")
+
+	_, err = client.Exec(ctx, []byte(`hi`), "acme", "default", "other")
+	assert.ErrorIs(t, err, se2.ErrServerError, "every plugin has its own circuit")
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, se2.CircuitHalfOpen, client.CircuitState(pure))
+
+	healthy.Store(true)
+
+	out, err := client.Exec(ctx, []byte(`hi`), "acme", "default", "pure")
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`ok`), out)
+	assert.Equal(t, se2.CircuitClosed, client.CircuitState(pure))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, []string{
+		"pure: closed -> open",
+		"pure: open -> half-open",
+		"pure: half-open -> closed",
+	}, transitions)
+}
+
+func TestWithCircuitBreaker_Fallback(t *testing.T) {
+	var requests int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL, srv.URL),
+		se2.WithCircuitBreaker(se2.BreakerPolicy{
+			Plugins:          []se2.ExecTarget{pure},
+			FailureThreshold: 1,
+			OpenTimeout:      time.Hour,
+			Fallback: func(ctx context.Context, target se2.ExecTarget, payload []byte, err error) ([]byte, error) {
+				assert.ErrorIs(t, err, se2.ErrCircuitOpen)
+
+				return append([]byte("fallback: "), payload...), nil
+			},
+		}),
+	)
+	require.NoError(t, err)
+
+	_, err = client.Exec(context.Background(), []byte(`hi`), "acme", "default", "pure")
+	assert.ErrorIs(t, err, se2.ErrServerError)
+
+	out, err := client.Exec(context.Background(), []byte(`hi`), "acme", "default", "pure")
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`fallback: hi`), out)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	for i := 0; i < 3; i++ {
+		_, err = client.Exec(context.Background(), []byte(`hi`), "acme", "default", "other")
+		assert.ErrorIs(t, err, se2.ErrServerError, "only the listed plugins have a circuit")
+	}
+
+	assert.Equal(t, se2.CircuitClosed, client.CircuitState(se2.ExecTarget{Ident: "acme", Namespace: "default", Plugin: "other"}))
+}
+
+func TestWithCircuitBreaker_ClientErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL, srv.URL),
+		se2.WithCircuitBreaker(se2.BreakerPolicy{FailureThreshold: 1}),
+	)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err = client.Exec(context.Background(), []byte(`hi`), "acme", "default", "pure")
+		assert.ErrorIs(t, err, se2.ErrBadRequest)
+	}
+
+	assert.Equal(t, se2.CircuitClosed, client.CircuitState(pure), "bad input is not the plugin's fault")
+}
+
+func TestWithCircuitBreaker_ErrorsBeforeSending(t *testing.T) {
+	var requests int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL, srv.URL),
+		se2.WithMaxRequestBytes(3),
+		se2.WithCircuitBreaker(se2.BreakerPolicy{FailureThreshold: 1}),
+	)
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		_, err = client.Exec(context.Background(), []byte(`too large`), "acme", "default", "pure")
+		assert.ErrorIs(t, err, se2.ErrPayloadTooLarge)
+	}
+
+	assert.Equal(t, se2.CircuitClosed, client.CircuitState(pure), "a payload over the limit is not the plugin's fault")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+
+	invalid := se2.ExecTarget{Ident: "acme", Namespace: "default", Plugin: "not/valid"}
+
+	_, err = client.Exec(context.Background(), []byte(`hi`), invalid.Ident, invalid.Namespace, invalid.Plugin)
+	assert.ErrorIs(t, err, se2.ErrInvalidFQMN)
+	assert.Equal(t, se2.CircuitClosed, client.CircuitState(invalid), "an invalid name is not the plugin's fault")
+
+	errNoToken := errors.New("no token")
+
+	client.SetTokenSource(se2.TokenSourceFunc(func(context.Context) (string, error) {
+		return "", errNoToken
+	}))
+
+	_, err = client.Exec(context.Background(), []byte(`hi`), "acme", "default", "pure")
+	assert.ErrorIs(t, err, errNoToken)
+	assert.Equal(t, se2.CircuitClosed, client.CircuitState(pure), "a missing access key is not the plugin's fault")
+
+	client.SetTokenSource(se2.TokenSourceFunc(func(context.Context) (string, error) {
+		return testAccessKey, nil
+	}))
+
+	_, err = client.Exec(context.Background(), []byte(`hi`), "acme", "default", "pure")
+	assert.ErrorIs(t, err, se2.ErrServerError)
+	assert.Equal(t, se2.CircuitOpen, client.CircuitState(pure), "5xx responses are")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestWithCircuitBreaker_MaxPlugins(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL, srv.URL),
+		se2.WithCircuitBreaker(se2.BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Hour, MaxPlugins: 2}),
+	)
+	require.NoError(t, err)
+
+	targets := []se2.ExecTarget{
+		{Ident: "acme", Namespace: "default", Plugin: "one"},
+		{Ident: "acme", Namespace: "default", Plugin: "two"},
+		{Ident: "acme", Namespace: "default", Plugin: "three"},
+	}
+
+	for _, target := range targets {
+		_, err = client.Exec(context.Background(), []byte(`hi`), target.Ident, target.Namespace, target.Plugin)
+		assert.ErrorIs(t, err, se2.ErrServerError)
+	}
+
+	assert.Equal(t, se2.CircuitClosed, client.CircuitState(targets[0]), "the least recently used circuit was dropped")
+	assert.Equal(t, se2.CircuitOpen, client.CircuitState(targets[1]))
+	assert.Equal(t, se2.CircuitOpen, client.CircuitState(targets[2]))
+}
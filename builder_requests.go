@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 
 	"github.com/pkg/errors"
@@ -28,12 +27,19 @@ type BuildPluginResponse struct {
 // BuildPlugin will attempt to build a plugin supplied by the raw byte slice in the context of the current session. The
 // language is set by the template, which you can control by calling the CreatePluginDraft method with the template
 // name.
-func (c *Client) BuildPlugin(ctx context.Context, pluginCode []byte, token CreateSessionResponse) (BuildPluginResponse, error) {
+func (c *Client) BuildPlugin(ctx context.Context, pluginCode []byte, token CreateSessionResponse) (_ BuildPluginResponse, err error) {
 	if len(pluginCode) == zeroLength {
 		return BuildPluginResponse{}, errors.New("client.BuildPlugin: can not build empty code")
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.host+pathBuild, bytes.NewReader(pluginCode))
+	op := token.operation("client.BuildPlugin")
+
+	ctx, span := c.startSpan(ctx, op)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	req, err := http.NewRequestWithContext(withOperation(ctx, op), http.MethodPost, c.host+pathBuild, bytes.NewReader(pluginCode))
 	if err != nil {
 		return BuildPluginResponse{}, errors.Wrap(err, "client.BuildPlugin: http.NewRequest")
 	}
@@ -48,17 +54,14 @@ func (c *Client) BuildPlugin(ctx context.Context, pluginCode []byte, token Creat
 	}()
 
 	if res.StatusCode != http.StatusCreated {
-		return BuildPluginResponse{}, fmt.Errorf(httpResponseCodeErrorFormat, "client.BuildPlugin", http.StatusCreated, res.StatusCode)
+		return BuildPluginResponse{}, newAPIError("client.BuildPlugin", http.StatusCreated, res)
 	}
 
 	var t BuildPluginResponse
 
-	dec := json.NewDecoder(res.Body)
-	dec.DisallowUnknownFields()
-
-	err = dec.Decode(&t)
+	err = c.decode(req.Context(), res, &t)
 	if err != nil {
-		return BuildPluginResponse{}, errors.Wrap(err, "client.BuildPlugin: dec.Decode")
+		return BuildPluginResponse{}, errors.Wrap(err, "client.BuildPlugin: c.decode")
 	}
 
 	return t, nil
@@ -78,8 +81,15 @@ type Languages struct {
 }
 
 // GetBuilderFeatures will return the features that the builder can provide.
-func (c *Client) GetBuilderFeatures(ctx context.Context) (BuilderFeaturesResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.host+pathBuilderFeatures, nil)
+func (c *Client) GetBuilderFeatures(ctx context.Context) (_ BuilderFeaturesResponse, err error) {
+	op := Operation{Name: "client.GetBuilderFeatures"}
+
+	ctx, span := c.startSpan(ctx, op)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	req, err := http.NewRequestWithContext(withOperation(ctx, op), http.MethodGet, c.host+pathBuilderFeatures, nil)
 	if err != nil {
 		return BuilderFeaturesResponse{}, errors.Wrap(err, "client.GetBuilderFeatures: http.NewRequest")
 	}
@@ -94,18 +104,15 @@ func (c *Client) GetBuilderFeatures(ctx context.Context) (BuilderFeaturesRespons
 	}()
 
 	if res.StatusCode != http.StatusOK {
-		return BuilderFeaturesResponse{}, fmt.Errorf(httpResponseCodeErrorFormat, "client.GetBuilderFeatures", http.StatusOK, res.StatusCode)
+		return BuilderFeaturesResponse{}, newAPIError("client.GetBuilderFeatures", http.StatusOK, res)
 	}
 
 	// Marshal response body into what we need to give back.
 	var t BuilderFeaturesResponse
 
-	dec := json.NewDecoder(res.Body)
-	dec.DisallowUnknownFields()
-
-	err = dec.Decode(&t)
+	err = c.decode(req.Context(), res, &t)
 	if err != nil {
-		return BuilderFeaturesResponse{}, errors.Wrap(err, "client.GetBuilderFeatures: dec.Decode")
+		return BuilderFeaturesResponse{}, errors.Wrap(err, "client.GetBuilderFeatures: c.decode")
 	}
 
 	return t, nil
@@ -125,8 +132,15 @@ type TestPluginDraftResponse struct {
 
 // TestPluginDraft will send the testData byte slice to the plugin that's currently in the draft as input, and return
 // the response that came back from the plugin.
-func (c *Client) TestPluginDraft(ctx context.Context, testData []byte, token CreateSessionResponse) (TestPluginDraftResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.host+pathTest, bytes.NewReader(testData))
+func (c *Client) TestPluginDraft(ctx context.Context, testData []byte, token CreateSessionResponse) (_ TestPluginDraftResponse, err error) {
+	op := token.operation("client.TestPluginDraft")
+
+	ctx, span := c.startSpan(ctx, op)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	req, err := http.NewRequestWithContext(withOperation(ctx, op), http.MethodPost, c.host+pathTest, bytes.NewReader(testData))
 	if err != nil {
 		return TestPluginDraftResponse{}, errors.Wrap(err, "client.TestPluginDraft: http.NewRequest")
 	}
@@ -141,17 +155,14 @@ func (c *Client) TestPluginDraft(ctx context.Context, testData []byte, token Cre
 	}()
 
 	if res.StatusCode != http.StatusOK {
-		return TestPluginDraftResponse{}, fmt.Errorf(httpResponseCodeErrorFormat, "client.TestPluginDraft", http.StatusOK, res.StatusCode)
+		return TestPluginDraftResponse{}, newAPIError("client.TestPluginDraft", http.StatusOK, res)
 	}
 
 	var t TestPluginDraftResponse
 
-	dec := json.NewDecoder(res.Body)
-	dec.DisallowUnknownFields()
-
-	err = dec.Decode(&t)
+	err = c.decode(req.Context(), res, &t)
 	if err != nil {
-		return TestPluginDraftResponse{}, errors.Wrap(err, "client.TestPluginDraft: dec.Decode")
+		return TestPluginDraftResponse{}, errors.Wrap(err, "client.TestPluginDraft: c.decode")
 	}
 
 	return t, nil
@@ -159,8 +170,15 @@ func (c *Client) TestPluginDraft(ctx context.Context, testData []byte, token Cre
 
 // GetPluginDraft returns the currently set plugin draft for the given session token. To change the draft or the
 // language you can use the CreatePluginDraft method instead with the name of a template.
-func (c *Client) GetPluginDraft(ctx context.Context, token CreateSessionResponse) (DraftResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.host+pathDraft, nil)
+func (c *Client) GetPluginDraft(ctx context.Context, token CreateSessionResponse) (_ DraftResponse, err error) {
+	op := token.operation("client.GetPluginDraft")
+
+	ctx, span := c.startSpan(ctx, op)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	req, err := http.NewRequestWithContext(withOperation(ctx, op), http.MethodGet, c.host+pathDraft, nil)
 	if err != nil {
 		return DraftResponse{}, errors.Wrap(err, "client.GetPluginDraft: http.NewRequest")
 	}
@@ -175,17 +193,14 @@ func (c *Client) GetPluginDraft(ctx context.Context, token CreateSessionResponse
 	}()
 
 	if res.StatusCode != http.StatusOK {
-		return DraftResponse{}, fmt.Errorf(httpResponseCodeErrorFormat, "client.GetPluginDraft", http.StatusOK, res.StatusCode)
+		return DraftResponse{}, newAPIError("client.GetPluginDraft", http.StatusOK, res)
 	}
 
 	var t DraftResponse
 
-	dec := json.NewDecoder(res.Body)
-	dec.DisallowUnknownFields()
-
-	err = dec.Decode(&t)
+	err = c.decode(req.Context(), res, &t)
 	if err != nil {
-		return DraftResponse{}, errors.Wrap(err, "client.GetPluginDraft: dec.Decode")
+		return DraftResponse{}, errors.Wrap(err, "client.GetPluginDraft: c.decode")
 	}
 
 	return t, nil
@@ -207,7 +222,7 @@ type createDraftRequest struct {
 // template for building and executing.
 //
 // To see available templates, use the ListTemplates method.
-func (c *Client) CreatePluginDraft(ctx context.Context, templateName string, token CreateSessionResponse) (DraftResponse, error) {
+func (c *Client) CreatePluginDraft(ctx context.Context, templateName string, token CreateSessionResponse) (_ DraftResponse, err error) {
 	if templateName == emptyString {
 		return DraftResponse{}, errors.New("client.CreatePluginDraft: template name cannot be blank")
 	}
@@ -216,12 +231,20 @@ func (c *Client) CreatePluginDraft(ctx context.Context, templateName string, tok
 
 	r := createDraftRequest{Template: templateName}
 
-	err := json.NewEncoder(&b).Encode(r)
+	err = json.NewEncoder(&b).Encode(r)
 	if err != nil {
 		return DraftResponse{}, errors.Wrapf(err, "client.CreatePluginDraft: json.NewEncoder.Encode(createDraftRequest with template name '%s'", templateName)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.host+pathDraft, &b)
+	op := token.operation("client.CreatePluginDraft")
+
+	ctx, span := c.startSpan(ctx, op)
+	defer func() {
+		endSpan(span, err)
+	}()
+	op.Template = templateName
+
+	req, err := http.NewRequestWithContext(withOperation(ctx, op), http.MethodPost, c.host+pathDraft, &b)
 	if err != nil {
 		return DraftResponse{}, errors.Wrap(err, "client.CreatePluginDraft: http.NewRequest")
 	}
@@ -236,17 +259,14 @@ func (c *Client) CreatePluginDraft(ctx context.Context, templateName string, tok
 	}()
 
 	if res.StatusCode != http.StatusOK {
-		return DraftResponse{}, fmt.Errorf(httpResponseCodeErrorFormat, "client.CreatePluginDraft", http.StatusOK, res.StatusCode)
+		return DraftResponse{}, newAPIError("client.CreatePluginDraft", http.StatusOK, res)
 	}
 
 	var t DraftResponse
 
-	dec := json.NewDecoder(res.Body)
-	dec.DisallowUnknownFields()
-
-	err = dec.Decode(&t)
+	err = c.decode(req.Context(), res, &t)
 	if err != nil {
-		return DraftResponse{}, errors.Wrap(err, "client.CreatePluginDraft: dec.Decode")
+		return DraftResponse{}, errors.Wrap(err, "client.CreatePluginDraft: c.decode")
 	}
 
 	return t, nil
@@ -258,8 +278,15 @@ type PromotePluginDraftResponse struct {
 }
 
 // PromotePluginDraft promotes the current version of the draft to the live version of the plugin.
-func (c *Client) PromotePluginDraft(ctx context.Context, token CreateSessionResponse) (PromotePluginDraftResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.host+pathPromote, nil)
+func (c *Client) PromotePluginDraft(ctx context.Context, token CreateSessionResponse) (_ PromotePluginDraftResponse, err error) {
+	op := token.operation("client.PromotePluginDraft")
+
+	ctx, span := c.startSpan(ctx, op)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	req, err := http.NewRequestWithContext(withOperation(ctx, op), http.MethodPost, c.host+pathPromote, nil)
 	if err != nil {
 		return PromotePluginDraftResponse{}, errors.Wrap(err, "client.PromotePluginDraft: http.NewRequest")
 	}
@@ -274,17 +301,14 @@ func (c *Client) PromotePluginDraft(ctx context.Context, token CreateSessionResp
 	}()
 
 	if res.StatusCode != http.StatusOK {
-		return PromotePluginDraftResponse{}, fmt.Errorf(httpResponseCodeErrorFormat, "client.PromotePluginDraft", http.StatusOK, res.StatusCode)
+		return PromotePluginDraftResponse{}, newAPIError("client.PromotePluginDraft", http.StatusOK, res)
 	}
 
 	var t PromotePluginDraftResponse
 
-	dec := json.NewDecoder(res.Body)
-	dec.DisallowUnknownFields()
-
-	err = dec.Decode(&t)
+	err = c.decode(req.Context(), res, &t)
 	if err != nil {
-		return PromotePluginDraftResponse{}, errors.Wrap(err, "client.PromotePluginDraft: dec.Decode")
+		return PromotePluginDraftResponse{}, errors.Wrap(err, "client.PromotePluginDraft: c.decode")
 	}
 
 	return t, nil
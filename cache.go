@@ -0,0 +1,305 @@
+package se2
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultExecCacheMaxEntries = 1024
+	defaultExecCacheTTL        = 5 * time.Minute
+)
+
+// ExecCacheOptions configures the exec cache, see WithExecCache.
+type ExecCacheOptions struct {
+	// Plugins are the plugins whose outputs are cached. Executions of any other plugin are not. Only list plugins that
+	// are deterministic, whose output only depends on their input.
+	Plugins []ExecTarget
+
+	// MaxEntries is how many outputs are kept at most. Defaults to 1024. MaxBytes, if set, also limits their total
+	// size. Once either limit is reached, the least recently used outputs are evicted first.
+	MaxEntries int
+	MaxBytes   int64
+
+	// TTL is how long an output is served from the cache. Defaults to 5 minutes.
+	TTL time.Duration
+}
+
+// ExecCacheStats is a snapshot of the exec cache.
+type ExecCacheStats struct {
+	// Hits and Misses count the executions of cached plugins that were and were not served from the cache.
+	Hits   uint64
+	Misses uint64
+
+	// Evictions counts the outputs dropped to make room, and Invalidations the ones dropped because the plugin got a
+	// new ref. Expired outputs are not counted.
+	Evictions     uint64
+	Invalidations uint64
+
+	// Entries is how many outputs are in the cache, and Bytes their total size.
+	Entries int
+	Bytes   int64
+}
+
+// WithExecCache turns on caching the outputs of Exec for the plugins in the options. An output is cached for the
+// ident, namespace, plugin, and ref of the plugin, the payload, and the headers and query parameters of the request.
+// Calls with ExecNoCache skip the cache.
+//
+// The cache learns the ref of a plugin from GetPlugins, as the responses of the edge do not say which version ran. When
+// GetPlugins reports a new ref, the outputs of the previous version are dropped. A promotion is only noticed the next
+// time GetPlugins is called for the tenant, so until then outputs of the previous version are served, for up to the
+// TTL. Call GetPlugins after promoting a plugin, or keep the TTL short, if that matters.
+func WithExecCache(opts ExecCacheOptions) ClientOption {
+	return func(c *Client) {
+		c.execCache = newExecCache(opts)
+	}
+}
+
+// ExecCacheStats returns a snapshot of the exec cache. It's all zeroes if the cache is not turned on.
+func (c *Client) ExecCacheStats() ExecCacheStats {
+	if c.execCache == nil {
+		return ExecCacheStats{}
+	}
+
+	return c.execCache.stats()
+}
+
+// PurgeExecCache drops every output from the exec cache.
+func (c *Client) PurgeExecCache() {
+	if c.execCache == nil {
+		return
+	}
+
+	c.execCache.purge()
+}
+
+// execCacheKey identifies a cached output.
+type execCacheKey struct {
+	target ExecTarget
+	ref    string
+	hash   [sha256.Size]byte
+}
+
+// execCacheEntry is a cached output, the value of the elements of the LRU list.
+type execCacheEntry struct {
+	key     execCacheKey
+	output  []byte
+	expires time.Time
+}
+
+// execCache is an LRU cache of plugin outputs with a TTL.
+type execCache struct {
+	maxEntries int
+	maxBytes   int64
+	ttl        time.Duration
+	plugins    map[ExecTarget]struct{}
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[execCacheKey]*list.Element
+	refs    map[ExecTarget]string
+	counts  ExecCacheStats
+}
+
+// newExecCache creates a cache with the options, filling in the defaults.
+func newExecCache(opts ExecCacheOptions) *execCache {
+	ec := &execCache{
+		maxEntries: opts.MaxEntries,
+		maxBytes:   opts.MaxBytes,
+		ttl:        opts.TTL,
+		plugins:    make(map[ExecTarget]struct{}, len(opts.Plugins)),
+		lru:        list.New(),
+		entries:    make(map[execCacheKey]*list.Element),
+		refs:       make(map[ExecTarget]string),
+	}
+
+	if ec.maxEntries <= 0 {
+		ec.maxEntries = defaultExecCacheMaxEntries
+	}
+
+	if ec.ttl <= 0 {
+		ec.ttl = defaultExecCacheTTL
+	}
+
+	for _, target := range opts.Plugins {
+		ec.plugins[target] = struct{}{}
+	}
+
+	return ec
+}
+
+// hash returns the hash of the payload and of the options that shape the request, or false if the execution should not
+// use the cache. It's safe to call on a nil cache.
+func (ec *execCache) hash(target ExecTarget, payload []byte, opts []ExecOption) ([sha256.Size]byte, bool) {
+	if ec == nil {
+		return [sha256.Size]byte{}, false
+	}
+
+	if _, ok := ec.plugins[target]; !ok {
+		return [sha256.Size]byte{}, false
+	}
+
+	o := newExecOptions(opts)
+	if o.noCache {
+		return [sha256.Size]byte{}, false
+	}
+
+	h := sha256.New()
+	_, _ = h.Write(payload)
+
+	keys := make([]string, 0, len(o.header))
+
+	for key := range o.header {
+		if key != headerIdempotencyKey {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range o.header[key] {
+			_, _ = h.Write([]byte("\x00" + key + ":" + value))
+		}
+	}
+
+	_, _ = h.Write([]byte("\x00?" + o.query.Encode()))
+
+	var sum [sha256.Size]byte
+
+	copy(sum[:], h.Sum(nil))
+
+	return sum, true
+}
+
+// get returns a copy of the output cached for the payload hash under the current ref of the plugin. It also returns
+// that ref, which is what a miss needs to be put under.
+func (ec *execCache) get(target ExecTarget, hash [sha256.Size]byte) ([]byte, string, bool) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	ref := ec.refs[target]
+
+	el, ok := ec.entries[execCacheKey{target: target, ref: ref, hash: hash}]
+	if !ok {
+		ec.counts.Misses++
+
+		return nil, ref, false
+	}
+
+	entry := el.Value.(*execCacheEntry)
+
+	if time.Now().After(entry.expires) {
+		ec.remove(el)
+		ec.counts.Misses++
+
+		return nil, ref, false
+	}
+
+	ec.lru.MoveToFront(el)
+	ec.counts.Hits++
+
+	return bytes.Clone(entry.output), ref, true
+}
+
+// put caches a copy of the output under the ref get returned for the miss. If GetPlugins reported a new ref while the
+// plugin ran, the output may be of the previous version, and it's not cached.
+func (ec *execCache) put(target ExecTarget, ref string, hash [sha256.Size]byte, output []byte) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	if ec.maxBytes > 0 && int64(len(output)) > ec.maxBytes {
+		return
+	}
+
+	if ec.refs[target] != ref {
+		return
+	}
+
+	key := execCacheKey{target: target, ref: ref, hash: hash}
+
+	if el, ok := ec.entries[key]; ok {
+		ec.remove(el)
+	}
+
+	ec.entries[key] = ec.lru.PushFront(&execCacheEntry{
+		key:     key,
+		output:  bytes.Clone(output),
+		expires: time.Now().Add(ec.ttl),
+	})
+	ec.counts.Entries++
+	ec.counts.Bytes += int64(len(output))
+
+	for ec.counts.Entries > ec.maxEntries || (ec.maxBytes > 0 && ec.counts.Bytes > ec.maxBytes) {
+		ec.remove(ec.lru.Back())
+		ec.counts.Evictions++
+	}
+}
+
+// observePlugins records the refs of the cached plugins of the tenant listed by GetPlugins. It's safe to call on a nil
+// cache.
+func (ec *execCache) observePlugins(tenant string, plugins []Plugin) {
+	if ec == nil {
+		return
+	}
+
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	for _, p := range plugins {
+		target := ExecTarget{Ident: tenant, Namespace: p.Namespace, Plugin: p.Name}
+
+		if _, ok := ec.plugins[target]; ok && p.Ref != emptyString {
+			ec.setRef(target, p.Ref)
+		}
+	}
+}
+
+// setRef records the current ref of the plugin, dropping its outputs if the ref changed. It needs to be called with
+// the lock held.
+func (ec *execCache) setRef(target ExecTarget, ref string) {
+	if ec.refs[target] == ref {
+		return
+	}
+
+	ec.refs[target] = ref
+
+	for key, el := range ec.entries {
+		if key.target == target {
+			ec.remove(el)
+			ec.counts.Invalidations++
+		}
+	}
+}
+
+// remove drops the element from the cache. It needs to be called with the lock held.
+func (ec *execCache) remove(el *list.Element) {
+	entry := ec.lru.Remove(el).(*execCacheEntry)
+
+	delete(ec.entries, entry.key)
+	ec.counts.Entries--
+	ec.counts.Bytes -= int64(len(entry.output))
+}
+
+// stats returns a snapshot of the counters.
+func (ec *execCache) stats() ExecCacheStats {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	return ec.counts
+}
+
+// purge drops every output.
+func (ec *execCache) purge() {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	ec.lru.Init()
+	ec.entries = make(map[execCacheKey]*list.Element)
+	ec.counts.Entries = 0
+	ec.counts.Bytes = 0
+}
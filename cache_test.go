@@ -0,0 +1,208 @@
+package se2_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+)
+
+// refServer answers with the current ref of the plugin and a count of the executions, and lists the plugin with that
+// ref.
+type refServer struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	ref   string
+	execs int
+}
+
+func newRefServer(t *testing.T) *refServer {
+	t.Helper()
+
+	rs := &refServer{ref: "v1"}
+
+	rs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+
+		if r.URL.Path == "/environment/v1/tenant/acme/plugins" {
+			_, _ = fmt.Fprintf(w, `{"plugins":[{"name":"pure","namespace":"default","lang":"js","ref":%q,"apiVersion":"","fqmn":"","uri":""}]}`, rs.ref)
+
+			return
+		}
+
+		rs.execs++
+
+		_, _ = fmt.Fprintf(w, "%s:%d", rs.ref, rs.execs)
+	}))
+
+	t.Cleanup(rs.Close)
+
+	return rs
+}
+
+func (rs *refServer) setRef(ref string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.ref = ref
+}
+
+var pure = se2.ExecTarget{Ident: "acme", Namespace: "default", Plugin: "pure"}
+
+func TestWithExecCache(t *testing.T) {
+	rs := newRefServer(t)
+	ctx := context.Background()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(rs.URL, rs.URL),
+		se2.WithExecCache(se2.ExecCacheOptions{Plugins: []se2.ExecTarget{pure}}),
+	)
+	require.NoError(t, err)
+
+	exec := func(payload string, opts ...se2.ExecOption) string {
+		out, err := client.Exec(ctx, []byte(payload), "acme", "default", "pure", opts...)
+		require.NoError(t, err)
+
+		return string(out)
+	}
+
+	assert.Equal(t, "v1:1", exec("a"))
+	assert.Equal(t, "v1:1", exec("a"), "served from the cache")
+	assert.Equal(t, "v1:2", exec("b"), "another payload")
+	assert.Equal(t, "v1:3", exec("a", se2.ExecHeader("X-Locale", "nl")), "another header")
+	assert.Equal(t, "v1:1", exec("a", se2.ExecIdempotencyKey("k")), "the idempotency key does not matter")
+	assert.Equal(t, "v1:4", exec("a", se2.ExecNoCache()))
+
+	out, err := client.Exec(ctx, []byte("a"), "acme", "default", "other")
+	require.NoError(t, err)
+	assert.Equal(t, "v1:5", string(out), "not a cached plugin")
+
+	rs.setRef("v2")
+
+	assert.Equal(t, "v1:1", exec("a"), "the new ref is not known yet")
+
+	_, err = client.GetPlugins(ctx, "acme")
+	require.NoError(t, err)
+
+	assert.Equal(t, "v2:6", exec("a"), "invalidated by GetPlugins")
+	assert.Equal(t, "v2:6", exec("a"))
+
+	stats := client.ExecCacheStats()
+	assert.Equal(t, uint64(4), stats.Hits)
+	assert.Equal(t, uint64(4), stats.Misses)
+	assert.Equal(t, uint64(3), stats.Invalidations)
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, int64(len("v2:6")), stats.Bytes)
+
+	client.PurgeExecCache()
+
+	assert.Equal(t, "v2:7", exec("a"))
+}
+
+func TestWithExecCache_Limits(t *testing.T) {
+	tests := []struct {
+		name string
+		opts se2.ExecCacheOptions
+		wait time.Duration
+	}{
+		{
+			name: "max entries",
+			opts: se2.ExecCacheOptions{Plugins: []se2.ExecTarget{pure}, MaxEntries: 1},
+		},
+		{
+			name: "max bytes",
+			opts: se2.ExecCacheOptions{Plugins: []se2.ExecTarget{pure}, MaxBytes: 5},
+		},
+		{
+			name: "ttl",
+			opts: se2.ExecCacheOptions{Plugins: []se2.ExecTarget{pure}, TTL: 10 * time.Millisecond},
+			wait: 20 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := newRefServer(t)
+
+			client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(rs.URL, rs.URL), se2.WithExecCache(tt.opts))
+			require.NoError(t, err)
+
+			for _, payload := range []string{"a", "b"} {
+				_, err = client.Exec(context.Background(), []byte(payload), "acme", "default", "pure")
+				require.NoError(t, err)
+			}
+
+			time.Sleep(tt.wait)
+
+			out, err := client.Exec(context.Background(), []byte("a"), "acme", "default", "pure")
+			require.NoError(t, err)
+
+			assert.Equal(t, "v1:3", string(out), "a is no longer cached")
+		})
+	}
+}
+
+func TestWithExecCache_RefChangedWhileRunning(t *testing.T) {
+	ref := "v1"
+	running := make(chan struct{})
+	release := make(chan struct{})
+
+	var execs int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/environment/v1/tenant/acme/plugins" {
+			_, _ = fmt.Fprintf(w, `{"plugins":[{"name":"pure","namespace":"default","lang":"js","ref":%q,"apiVersion":"","fqmn":"","uri":""}]}`, ref)
+
+			return
+		}
+
+		execs++
+		if execs == 1 {
+			close(running)
+			<-release
+		}
+
+		_, _ = fmt.Fprintf(w, "%d", execs)
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL, srv.URL),
+		se2.WithExecCache(se2.ExecCacheOptions{Plugins: []se2.ExecTarget{pure}}),
+	)
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		_, err := client.Exec(context.Background(), []byte("a"), "acme", "default", "pure")
+		assert.NoError(t, err)
+	}()
+
+	<-running
+
+	// The plugin is promoted while the first execution is still running the previous version.
+	ref = "v2"
+
+	_, err = client.GetPlugins(context.Background(), "acme")
+	require.NoError(t, err)
+
+	close(release)
+	<-done
+
+	out, err := client.Exec(context.Background(), []byte("a"), "acme", "default", "pure")
+	require.NoError(t, err)
+	assert.Equal(t, "2", string(out), "the output of the previous version was not cached under the new ref")
+}
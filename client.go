@@ -1,32 +1,41 @@
 package se2
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	modeUnset ServerMode = iota
 	ModeStaging
 	ModeProduction
-	hostProduction              string = "https://api.suborbital.network"
-	hostStaging                 string = "https://stg.api.suborbital.network"
-	hostExecProduction          string = "https://edge.suborbital.network"
-	hostExecStaging             string = "https://stg.edge.suborbital.network"
-	minAccessKeyLength                 = 60
-	defaultTimeout                     = 60 * time.Second
-	emptyString                 string = ""
-	zeroLength                  int    = 0
-	httpResponseCodeErrorFormat        = "%s: expected http response code to be %d, got %d"
+	ModeCustom
+	hostProduction     string = "https://api.suborbital.network"
+	hostStaging        string = "https://stg.api.suborbital.network"
+	hostExecProduction string = "https://edge.suborbital.network"
+	hostExecStaging    string = "https://stg.edge.suborbital.network"
+	minAccessKeyLength        = 60
+	defaultTimeout            = 60 * time.Second
+	emptyString        string = ""
+	zeroLength         int    = 0
 )
 
 var (
 	ErrNoAccessKey = errors.New("no access key provided, or it's likely malformed")
-	ErrUnknownMode = errors.New("unknown client mode set. Use one of the ModeStaging or ModeProduction constants")
+	ErrUnknownMode = errors.New("unknown client mode set. Use one of the ModeStaging, ModeProduction, or ModeCustom constants")
+	ErrNoHosts     = errors.New("no hosts configured. ModeCustom needs both the admin and exec hosts set with WithHosts")
+	ErrInvalidHost = errors.New("host is not a valid absolute http or https URL")
 )
 
 // ServerMode is an alias type to help ensure that only the options we declared here can be used.
@@ -43,9 +52,25 @@ type accessKey struct {
 // our API without having to call actual http requests.
 type Client struct {
 	httpClient *http.Client
+	doer       Doer
+	middleware []Middleware
 	host       string
 	execHost   string
-	token      string
+	token      atomic.Pointer[tokenSourceBox]
+
+	retryPolicy  RetryPolicy
+	adminLimiter *tokenBucket
+	execLimiter  *tokenBucket
+	tracer       trace.Tracer
+	propagator   propagation.TextMapPropagator
+	logger       *slog.Logger
+
+	maxResponseBytes     int64
+	maxExecResponseBytes int64
+	maxRequestBytes      int64
+	execCache            *execCache
+	hedger               *hedger
+	breakers             *breakers
 }
 
 // ClientOption is a function signature users can use to configure different parts of the client. They are run at the
@@ -56,9 +81,33 @@ type ClientOption func(*Client)
 // whether it's the production or the staging environment, and an access key you can grab from the SE2 admin area for
 // an environment.
 //
+// For self-hosted installations, local stand-ins, or test servers use ModeCustom together with the WithHosts option.
+//
 // By default, the underlying http client has a 60-second timeout. Otherwise, you can use the
 // WithHTTPClient(*http.Client) function to use your own configured version for it.
 func NewClient(mode ServerMode, token string, options ...ClientOption) (*Client, error) {
+	// If the access key is malformed, return error.
+	err := validateAccessKey(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return newClient(mode, staticTokenSource(token), options)
+}
+
+// NewClientWithTokenSource returns a configured client like NewClient does, except that the access key is taken from
+// the token source for every request, so it can be rotated without rebuilding the client. See FileTokenSource and
+// TokenSourceFunc.
+func NewClientWithTokenSource(mode ServerMode, ts TokenSource, options ...ClientOption) (*Client, error) {
+	if ts == nil {
+		return nil, ErrNoAccessKey
+	}
+
+	return newClient(mode, ts, options)
+}
+
+// newClient sets up the client for the mode and the token source, and applies the options to it.
+func newClient(mode ServerMode, ts TokenSource, options []ClientOption) (*Client, error) {
 	// Create zero value client with default http client.
 	nc := Client{
 		httpClient: defaultHTTPClient(),
@@ -72,19 +121,78 @@ func NewClient(mode ServerMode, token string, options ...ClientOption) (*Client,
 	case ModeProduction:
 		nc.host = hostProduction
 		nc.execHost = hostExecProduction
+	case ModeCustom:
+		// Hosts are provided by the WithHosts option and checked once all options have been applied.
 	default:
 		return nil, ErrUnknownMode
 	}
 
-	// If access key is too short after modifiers, return error.
+	// Save the token source to the client.
+	nc.SetTokenSource(ts)
+
+	// Apply all the modifiers.
+	for _, o := range options {
+		o(&nc)
+	}
+
+	// Wrap the http client in the middleware now that we have both.
+	nc.doer = chain(nc.httpClient, nc.middleware)
+
+	// Make sure the hosts, whether set by the mode or by an option, are usable.
+	if nc.host == emptyString || nc.execHost == emptyString {
+		return nil, ErrNoHosts
+	}
+
+	var err error
+
+	nc.host, err = normalizeHost(nc.host)
+	if err != nil {
+		return nil, errors.Wrap(err, "admin host")
+	}
+
+	nc.execHost, err = normalizeHost(nc.execHost)
+	if err != nil {
+		return nil, errors.Wrap(err, "exec host")
+	}
+
+	return &nc, nil
+}
+
+// normalizeHost checks that the passed in host is an absolute http or https URL without query or fragment parts, and
+// strips the trailing slash, so we can append the request paths to it.
+func normalizeHost(host string) (string, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return emptyString, errors.Wrapf(ErrInvalidHost, "url.Parse %q: %s", host, err.Error())
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return emptyString, errors.Wrapf(ErrInvalidHost, "%q: scheme must be http or https", host)
+	}
+
+	if u.Host == emptyString {
+		return emptyString, errors.Wrapf(ErrInvalidHost, "%q: missing host", host)
+	}
+
+	if u.RawQuery != emptyString || u.Fragment != emptyString {
+		return emptyString, errors.Wrapf(ErrInvalidHost, "%q: must not have a query or fragment", host)
+	}
+
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+// validateAccessKey makes sure that the token is an access key of the correct form and structure: a base64 encoded JSON
+// object with the key and the secret.
+func validateAccessKey(token string) error {
+	// If access key is too short, return error.
 	if len(token) < minAccessKeyLength {
-		return nil, ErrNoAccessKey
+		return ErrNoAccessKey
 	}
 
 	// If access key is not a base64 encoded string, return error.
 	decoded, err := base64.StdEncoding.DecodeString(token)
 	if err != nil {
-		return nil, ErrNoAccessKey
+		return ErrNoAccessKey
 	}
 
 	// If access key is not a valid JSON base64 encoded, return error.
@@ -92,18 +200,10 @@ func NewClient(mode ServerMode, token string, options ...ClientOption) (*Client,
 
 	err = json.Unmarshal(decoded, &akUnmarshaled)
 	if err != nil {
-		return nil, ErrNoAccessKey
+		return ErrNoAccessKey
 	}
 
-	// Save the good token to the client.
-	nc.token = token
-
-	// Apply all the modifiers.
-	for _, o := range options {
-		o(&nc)
-	}
-
-	return &nc, nil
+	return nil
 }
 
 // defaultHTTPClient returns an http.Client with a 60-second timeout that's used until the users decide to change it by
@@ -121,14 +221,29 @@ func WithHTTPClient(client *http.Client) func(*Client) {
 	}
 }
 
+// WithHosts sets the admin host (tenants, templates, sessions, and the builder) and the exec host (the edge that runs
+// plugins). It is required for ModeCustom, and overrides the hosts of the other modes. Both values need to be absolute
+// http or https URLs, for example "http://localhost:8080". A path is allowed, which is useful behind a reverse proxy.
+func WithHosts(adminURL, execURL string) ClientOption {
+	return func(c *Client) {
+		c.host = adminURL
+		c.execHost = execURL
+	}
+}
+
 // do is the meat of the client, every other admin level exported method uses this. Its main job is to add the
-// authorization header with the access key to outgoing requests.
+// authorization header with the current access key from the token source to outgoing requests.
 func (c *Client) do(req *http.Request) (*http.Response, error) {
-	req.Header.Set("Authorization", "Bearer "+c.token)
+	token, err := c.accessKey(req.Context())
+	if err != nil {
+		return nil, errors.Wrap(err, "c.accessKey")
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.sendWithRetries(req)
 	if err != nil {
-		return nil, errors.Wrap(err, "c.httpClient.do")
+		return nil, errors.Wrap(err, "c.sendWithRetries")
 	}
 
 	return res, nil
@@ -139,10 +254,36 @@ func (c *Client) do(req *http.Request) (*http.Response, error) {
 func (c *Client) sessionDo(req *http.Request, token CreateSessionResponse) (*http.Response, error) {
 	req.Header.Add("Authorization", "Bearer "+token.Token)
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.sendWithRetries(req)
 	if err != nil {
-		return nil, errors.Wrap(err, "c.httpClient.do")
+		return nil, errors.Wrap(err, "c.sendWithRetries")
 	}
 
 	return res, nil
 }
+
+// decode unmarshals the json body of the response into v, refusing fields that v does not have. Failures are logged
+// with the operation in ctx, which is the context of the request. The Request field of the response is not used for
+// it, as responses made up by a middleware may not have one.
+func (c *Client) decode(ctx context.Context, res *http.Response, v any) error {
+	err := limitResponse(res, c.maxResponseBytes)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(res.Body)
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(v)
+	if err != nil {
+		c.log(ctx, slog.LevelError, "se2: could not decode response",
+			slog.Int("status", res.StatusCode),
+			slog.String("request_id", requestID(res)),
+			slog.String("error", err.Error()),
+		)
+
+		return errors.Wrap(err, "dec.Decode")
+	}
+
+	return nil
+}
@@ -1,13 +1,19 @@
 package se2_test
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/suborbital/se2-go"
 )
 
+const testAccessKey = "eyJrZXkiOjQwNywic2VjcmV0IjoiZWsvNFV3VTBnZ2VHUjdQanF1MmlyaWJacGR1MXZvcWNhMXl3eDE3aWhpTT0ifQ=="
+
 func TestNewClient2(t *testing.T) {
 	type args struct {
 		mode    se2.ServerMode
@@ -36,6 +42,48 @@ func TestNewClient2(t *testing.T) {
 			},
 			wantErr: assert.NoError,
 		},
+		{
+			name: "correct for custom, valid key, with hosts",
+			args: args{
+				mode:    se2.ModeCustom,
+				ak:      "eyJrZXkiOjQwNywic2VjcmV0IjoiZWsvNFV3VTBnZ2VHUjdQanF1MmlyaWJacGR1MXZvcWNhMXl3eDE3aWhpTT0ifQ==",
+				options: []se2.ClientOption{se2.WithHosts("http://localhost:8080/", "http://localhost:8081")},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "correct for custom, valid key, no hosts",
+			args: args{
+				mode: se2.ModeCustom,
+				ak:   "eyJrZXkiOjQwNywic2VjcmV0IjoiZWsvNFV3VTBnZ2VHUjdQanF1MmlyaWJacGR1MXZvcWNhMXl3eDE3aWhpTT0ifQ==",
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "correct for custom, valid key, relative admin host",
+			args: args{
+				mode:    se2.ModeCustom,
+				ak:      "eyJrZXkiOjQwNywic2VjcmV0IjoiZWsvNFV3VTBnZ2VHUjdQanF1MmlyaWJacGR1MXZvcWNhMXl3eDE3aWhpTT0ifQ==",
+				options: []se2.ClientOption{se2.WithHosts("localhost:8080", "http://localhost:8081")},
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "correct for custom, valid key, exec host with query",
+			args: args{
+				mode:    se2.ModeCustom,
+				ak:      "eyJrZXkiOjQwNywic2VjcmV0IjoiZWsvNFV3VTBnZ2VHUjdQanF1MmlyaWJacGR1MXZvcWNhMXl3eDE3aWhpTT0ifQ==",
+				options: []se2.ClientOption{se2.WithHosts("http://localhost:8080", "http://localhost:8081?a=b")},
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "unset mode, valid key, no options",
+			args: args{
+				ak: "eyJrZXkiOjQwNywic2VjcmV0IjoiZWsvNFV3VTBnZ2VHUjdQanF1MmlyaWJacGR1MXZvcWNhMXl3eDE3aWhpTT0ifQ==",
+			},
+			wantErr: assert.Error,
+		},
 		{
 			name: "correct for prod, empty key, no options",
 			args: args{
@@ -70,3 +118,31 @@ func TestNewClient2(t *testing.T) {
 		})
 	}
 }
+
+func TestNewClient_CustomHosts(t *testing.T) {
+	admin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/environment/v1/tenant/acme", r.URL.Path)
+		assert.Equal(t, "Bearer "+testAccessKey, r.Header.Get("Authorization"))
+
+		_, _ = w.Write([]byte(`{"authorized_party":"","id":"1","environment":"env","name":"acme","description":""}`))
+	}))
+	defer admin.Close()
+
+	edge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/name/acme/default/hello", r.URL.Path)
+
+		_, _ = w.Write([]byte(`hello back`))
+	}))
+	defer edge.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(admin.URL+"/", edge.URL))
+	require.NoError(t, err)
+
+	tenant, err := client.GetTenantByName(context.Background(), "acme")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", tenant.Name)
+
+	out, err := client.Exec(context.Background(), []byte(`hello`), "acme", "default", "hello")
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`hello back`), out)
+}
@@ -0,0 +1,125 @@
+package se2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// maxDecodeSnippetLength caps how much of the plugin output is kept on a DecodeError.
+const maxDecodeSnippetLength = 256
+
+// Codec turns values into plugin input, and plugin output back into values. JSONCodec is the default, the codec
+// package has MessagePack and Protocol Buffers implementations.
+type Codec interface {
+	// ContentType is sent as the Content-Type header of the request.
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec encodes values as JSON with encoding/json.
+type JSONCodec struct{}
+
+// ContentType returns application/json.
+func (JSONCodec) ContentType() string {
+	return "application/json"
+}
+
+// Marshal encodes v as JSON.
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "json.Marshal")
+	}
+
+	return b, nil
+}
+
+// Unmarshal decodes the JSON data into v.
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	err := json.Unmarshal(data, v)
+	if err != nil {
+		return errors.Wrap(err, "json.Unmarshal")
+	}
+
+	return nil
+}
+
+// DecodeError is returned by the typed exec helpers when the plugin ran, but its output could not be decoded into the
+// output type. It carries the start of the output to help figure out what the plugin returned instead.
+type DecodeError struct {
+	// ContentType is the content type of the codec that failed.
+	ContentType string
+
+	// Snippet is the start of the plugin output, truncated to 256 bytes, and Size is the length of the whole output.
+	Snippet []byte
+	Size    int
+
+	Err error
+}
+
+// Error implements the error interface.
+func (e *DecodeError) Error() string {
+	snippet := string(e.Snippet)
+	if len(e.Snippet) < e.Size {
+		snippet += "..."
+	}
+
+	return fmt.Sprintf("could not decode %d bytes of plugin output as %s: %s: %q", e.Size, e.ContentType, e.Err.Error(), snippet)
+}
+
+// Unwrap returns the error of the codec.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// ExecJSON runs the plugin with the input encoded as JSON, and decodes its JSON output into Out. If the output is not
+// valid JSON for Out, the error is a *DecodeError.
+func ExecJSON[In, Out any](ctx context.Context, c *Client, in In, ident, namespace, plugin string, opts ...ExecOption) (Out, error) {
+	return ExecCodec[In, Out](ctx, c, JSONCodec{}, in, ident, namespace, plugin, opts...)
+}
+
+// ExecCodec runs the plugin with the input encoded by the codec, and decodes its output into Out with the same codec.
+// The Content-Type of the request is set to that of the codec, unless an ExecContentType option overrides it. If the
+// output can not be decoded, the error is a *DecodeError.
+func ExecCodec[In, Out any](ctx context.Context, c *Client, codec Codec, in In, ident, namespace, plugin string, opts ...ExecOption) (Out, error) {
+	var out Out
+
+	payload, err := codec.Marshal(in)
+	if err != nil {
+		return out, errors.Wrap(err, "se2.ExecCodec: codec.Marshal")
+	}
+
+	opts = append([]ExecOption{ExecContentType(codec.ContentType())}, opts...)
+
+	b, err := c.Exec(ctx, payload, ident, namespace, plugin, opts...)
+	if err != nil {
+		return out, errors.Wrap(err, "se2.ExecCodec")
+	}
+
+	err = codec.Unmarshal(b, &out)
+	if err != nil {
+		return out, newDecodeError(codec, b, err)
+	}
+
+	return out, nil
+}
+
+// newDecodeError creates a *DecodeError for the output that the codec failed to decode.
+func newDecodeError(codec Codec, output []byte, err error) *DecodeError {
+	snippet := output
+	if len(snippet) > maxDecodeSnippetLength {
+		snippet = snippet[:maxDecodeSnippetLength]
+	}
+
+	return &DecodeError{
+		ContentType: codec.ContentType(),
+		Snippet:     bytes.Clone(snippet),
+		Size:        len(output),
+		Err:         err,
+	}
+}
@@ -0,0 +1,118 @@
+// Package codec has se2.Codec implementations for binary formats, to use with se2.ExecCodec.
+package codec
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/suborbital/se2-go"
+)
+
+// ErrNotProtoMessage is returned by Protobuf when a value is not a proto.Message.
+var ErrNotProtoMessage = errors.New("value is not a proto.Message")
+
+// MsgPack encodes values as MessagePack.
+type MsgPack struct{}
+
+var _ se2.Codec = MsgPack{}
+
+// ContentType returns application/msgpack.
+func (MsgPack) ContentType() string {
+	return "application/msgpack"
+}
+
+// Marshal encodes v as MessagePack.
+func (MsgPack) Marshal(v any) ([]byte, error) {
+	b, err := msgpack.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "msgpack.Marshal")
+	}
+
+	return b, nil
+}
+
+// Unmarshal decodes the MessagePack data into v.
+func (MsgPack) Unmarshal(data []byte, v any) error {
+	err := msgpack.Unmarshal(data, v)
+	if err != nil {
+		return errors.Wrap(err, "msgpack.Unmarshal")
+	}
+
+	return nil
+}
+
+// Protobuf encodes values in the Protocol Buffers wire format. The values need to be proto.Message implementations,
+// so with se2.ExecCodec, use pointers to the generated message types for In and Out:
+//
+//	out, err := se2.ExecCodec[*pb.Request, *pb.Response](ctx, client, codec.Protobuf{}, req, ident, namespace, plugin)
+type Protobuf struct{}
+
+var _ se2.Codec = Protobuf{}
+
+// ContentType returns application/x-protobuf.
+func (Protobuf) ContentType() string {
+	return "application/x-protobuf"
+}
+
+// Marshal encodes v, which needs to be a proto.Message.
+func (Protobuf) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, errors.Wrapf(ErrNotProtoMessage, "%T", v)
+	}
+
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return nil, errors.Wrap(err, "proto.Marshal")
+	}
+
+	return b, nil
+}
+
+// Unmarshal decodes data into v, which needs to be a proto.Message, or a pointer to a nil pointer of a generated
+// message type, as se2.ExecCodec passes it. In the latter case a new message is allocated.
+func (Protobuf) Unmarshal(data []byte, v any) error {
+	m, err := protoTarget(v)
+	if err != nil {
+		return err
+	}
+
+	err = proto.Unmarshal(data, m)
+	if err != nil {
+		return errors.Wrap(err, "proto.Unmarshal")
+	}
+
+	return nil
+}
+
+// protoTarget returns the message to unmarshal into. ExecCodec passes a pointer to Out, and Out is a pointer to a
+// generated message type, so v is usually a **Message. The pointed-to message is allocated if it's nil.
+func protoTarget(v any) (proto.Message, error) {
+	if m, ok := v.(proto.Message); ok {
+		return m, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return nil, errors.Wrapf(ErrNotProtoMessage, "%T", v)
+	}
+
+	elem := rv.Elem()
+	if elem.Kind() != reflect.Pointer {
+		return nil, errors.Wrapf(ErrNotProtoMessage, "%T", v)
+	}
+
+	if elem.IsNil() {
+		elem.Set(reflect.New(elem.Type().Elem()))
+	}
+
+	m, ok := elem.Interface().(proto.Message)
+	if !ok {
+		return nil, errors.Wrapf(ErrNotProtoMessage, "%T", v)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,59 @@
+package codec_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/suborbital/se2-go"
+	"github.com/suborbital/se2-go/codec"
+)
+
+const testAccessKey = "eyJrZXkiOjQwNywic2VjcmV0IjoiZWsvNFV3VTBnZ2VHUjdQanF1MmlyaWJacGR1MXZvcWNhMXl3eDE3aWhpTT0ifQ=="
+
+// echoClient returns a client for a server that sends the request body back with the same content type.
+func echoClient(t *testing.T) *se2.Client {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		_, _ = w.Write(b)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	return client
+}
+
+func TestMsgPack(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+
+	out, err := se2.ExecCodec[point, point](context.Background(), echoClient(t), codec.MsgPack{}, point{X: 1, Y: 2}, "acme", "default", "echo")
+	require.NoError(t, err)
+	assert.Equal(t, point{X: 1, Y: 2}, out)
+}
+
+func TestProtobuf(t *testing.T) {
+	client := echoClient(t)
+
+	out, err := se2.ExecCodec[*wrapperspb.StringValue, *wrapperspb.StringValue](context.Background(), client, codec.Protobuf{}, wrapperspb.String("engage"), "acme", "default", "echo")
+	require.NoError(t, err)
+	assert.Equal(t, "engage", out.GetValue())
+
+	_, err = se2.ExecCodec[string, string](context.Background(), client, codec.Protobuf{}, "engage", "acme", "default", "echo")
+	assert.ErrorIs(t, err, codec.ErrNotProtoMessage)
+}
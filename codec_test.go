@@ -0,0 +1,62 @@
+package se2_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+)
+
+type greetIn struct {
+	Name string `json:"name"`
+}
+
+type greetOut struct {
+	Greeting string `json:"greeting"`
+}
+
+func TestExecJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		b, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		if r.URL.Path == "/name/acme/default/broken" {
+			_, _ = w.Write([]byte(strings.Repeat("<html>", 100)))
+
+			return
+		}
+
+		assert.JSONEq(t, `{"name":"picard"}`, string(b))
+
+		_, _ = w.Write([]byte(`{"greeting":"hello picard"}`))
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	out, err := se2.ExecJSON[greetIn, greetOut](context.Background(), client, greetIn{Name: "picard"}, "acme", "default", "greet")
+	require.NoError(t, err)
+	assert.Equal(t, greetOut{Greeting: "hello picard"}, out)
+
+	_, err = se2.ExecJSON[greetIn, greetOut](context.Background(), client, greetIn{Name: "picard"}, "acme", "default", "broken")
+	require.Error(t, err)
+
+	var decodeErr *se2.DecodeError
+	require.True(t, errors.As(err, &decodeErr))
+
+	assert.Equal(t, "application/json", decodeErr.ContentType)
+	assert.Equal(t, 600, decodeErr.Size)
+	assert.Len(t, decodeErr.Snippet, 256)
+	assert.True(t, strings.HasPrefix(string(decodeErr.Snippet), "<html>"))
+}
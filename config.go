@@ -0,0 +1,228 @@
+package se2
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables read by NewClientFromEnv.
+const (
+	EnvToken      = "SE2_TOKEN"
+	EnvTokenFile  = "SE2_TOKEN_FILE"
+	EnvMode       = "SE2_MODE"
+	EnvAdminURL   = "SE2_ADMIN_URL"
+	EnvExecURL    = "SE2_EXEC_URL"
+	EnvTimeout    = "SE2_TIMEOUT"
+	EnvProfile    = "SE2_PROFILE"
+	EnvConfigFile = "SE2_CONFIG"
+)
+
+const configFileName = "config.yaml"
+
+var (
+	ErrProfileNotFound = errors.New("profile not found in the config file")
+	ErrInvalidTimeout  = errors.New("timeout is not a valid positive duration, like 30s or 2m")
+)
+
+// Profile is a named set of settings for a client in the config file.
+type Profile struct {
+	// Mode is one of "staging", "production", or "custom".
+	Mode string `yaml:"mode"`
+
+	// Token is the environment access key.
+	Token string `yaml:"token"`
+
+	// TokenFile is the path of a file with the environment access key in it, which is read again when it changes. It's
+	// used instead of Token when set, see FileTokenSource.
+	TokenFile string `yaml:"token_file"`
+
+	// AdminURL and ExecURL are the hosts for ModeCustom, see WithHosts.
+	AdminURL string `yaml:"admin_url"`
+	ExecURL  string `yaml:"exec_url"`
+
+	// Timeout is the timeout of the http client, like "30s". Empty means the default of 60 seconds.
+	Timeout string `yaml:"timeout"`
+}
+
+// configFile is the structure of the config file:
+//
+//	profiles:
+//	  staging:
+//	    mode: staging
+//	    token_file: /var/run/secrets/se2/token
+//	  local:
+//	    mode: custom
+//	    token: eyJr...
+//	    admin_url: http://localhost:8080
+//	    exec_url: http://localhost:8081
+//	    timeout: 10s
+type configFile struct {
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// ParseMode returns the ServerMode for its name: "staging", "production", or "custom". Case does not matter.
+func ParseMode(name string) (ServerMode, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "staging":
+		return ModeStaging, nil
+	case "production":
+		return ModeProduction, nil
+	case "custom":
+		return ModeCustom, nil
+	default:
+		return modeUnset, errors.Wrapf(ErrUnknownMode, "%q", name)
+	}
+}
+
+// DefaultConfigPath returns the path of the config file: the value of SE2_CONFIG if it's set, otherwise config.yaml in
+// the se2 directory of the user's config directory, for example ~/.config/se2/config.yaml on Linux.
+func DefaultConfigPath() (string, error) {
+	if path, ok := os.LookupEnv(EnvConfigFile); ok && path != emptyString {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return emptyString, errors.Wrap(err, "os.UserConfigDir")
+	}
+
+	return filepath.Join(dir, "se2", configFileName), nil
+}
+
+// LoadProfile reads the named profile from the config file at path.
+func LoadProfile(path, name string) (Profile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Profile{}, errors.Wrap(err, "os.ReadFile")
+	}
+
+	var cf configFile
+
+	err = yaml.Unmarshal(b, &cf)
+	if err != nil {
+		return Profile{}, errors.Wrapf(err, "yaml.Unmarshal %s", path)
+	}
+
+	p, ok := cf.Profiles[name]
+	if !ok {
+		return Profile{}, errors.Wrapf(ErrProfileNotFound, "%q in %s", name, path)
+	}
+
+	return p, nil
+}
+
+// NewClientFromProfile creates a client with the settings of the named profile in the config file, see
+// DefaultConfigPath. The options are applied after the ones derived from the profile, so they take precedence.
+func NewClientFromProfile(name string, options ...ClientOption) (*Client, error) {
+	path, err := DefaultConfigPath()
+	if err != nil {
+		return nil, errors.Wrap(err, "se2.NewClientFromProfile: DefaultConfigPath")
+	}
+
+	p, err := LoadProfile(path, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "se2.NewClientFromProfile: LoadProfile")
+	}
+
+	c, err := p.newClient(options)
+	if err != nil {
+		return nil, errors.Wrapf(err, "se2.NewClientFromProfile: profile %q", name)
+	}
+
+	return c, nil
+}
+
+// NewClientFromEnv creates a client from environment variables:
+//
+//   - SE2_TOKEN is the environment access key.
+//   - SE2_TOKEN_FILE is the path of a file with the access key in it, used instead of SE2_TOKEN when set.
+//   - SE2_MODE is one of "staging", "production", or "custom". Defaults to production.
+//   - SE2_ADMIN_URL and SE2_EXEC_URL are the hosts for the custom mode, see WithHosts.
+//   - SE2_TIMEOUT is the timeout of the http client, like "30s".
+//
+// If SE2_PROFILE is set, the named profile is loaded from the config file first, see DefaultConfigPath, and the other
+// variables override its settings. The options are applied after the ones derived from the environment, so they take
+// precedence.
+func NewClientFromEnv(options ...ClientOption) (*Client, error) {
+	var p Profile
+
+	if name, ok := os.LookupEnv(EnvProfile); ok && name != emptyString {
+		path, err := DefaultConfigPath()
+		if err != nil {
+			return nil, errors.Wrap(err, "se2.NewClientFromEnv: DefaultConfigPath")
+		}
+
+		p, err = LoadProfile(path, name)
+		if err != nil {
+			return nil, errors.Wrap(err, "se2.NewClientFromEnv: LoadProfile")
+		}
+	}
+
+	for env, field := range map[string]*string{
+		EnvToken:     &p.Token,
+		EnvTokenFile: &p.TokenFile,
+		EnvMode:      &p.Mode,
+		EnvAdminURL:  &p.AdminURL,
+		EnvExecURL:   &p.ExecURL,
+		EnvTimeout:   &p.Timeout,
+	} {
+		if value, ok := os.LookupEnv(env); ok && value != emptyString {
+			*field = value
+		}
+	}
+
+	c, err := p.newClient(options)
+	if err != nil {
+		return nil, errors.Wrap(err, "se2.NewClientFromEnv")
+	}
+
+	return c, nil
+}
+
+// newClient creates a client with the settings of the profile, followed by the extra options.
+func (p Profile) newClient(extra []ClientOption) (*Client, error) {
+	mode := ModeProduction
+
+	if p.Mode != emptyString {
+		var err error
+
+		mode, err = ParseMode(p.Mode)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var options []ClientOption
+
+	if p.AdminURL != emptyString || p.ExecURL != emptyString {
+		options = append(options, WithHosts(p.AdminURL, p.ExecURL))
+	}
+
+	if p.Timeout != emptyString {
+		timeout, err := time.ParseDuration(p.Timeout)
+		if err != nil || timeout <= 0 {
+			return nil, errors.Wrapf(ErrInvalidTimeout, "%q", p.Timeout)
+		}
+
+		options = append(options, WithHTTPClient(&http.Client{Timeout: timeout}))
+	}
+
+	options = append(options, extra...)
+
+	if p.TokenFile != emptyString {
+		ts, err := FileTokenSource(p.TokenFile, 0)
+		if err != nil {
+			return nil, errors.Wrap(err, "FileTokenSource")
+		}
+
+		return NewClientWithTokenSource(mode, ts, options...)
+	}
+
+	return NewClient(mode, p.Token, options...)
+}
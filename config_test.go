@@ -0,0 +1,143 @@
+package se2_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+)
+
+func TestNewClientFromEnv(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"tenants":[]}`))
+	}))
+	defer srv.Close()
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+
+	err := os.WriteFile(configPath, []byte(`profiles:
+  local:
+    mode: custom
+    token: `+testAccessKey+`
+    admin_url: `+srv.URL+`
+    exec_url: `+srv.URL+`
+    timeout: 5s
+  broken:
+    mode: custom
+    token: `+testAccessKey+`
+`), 0o600)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name: "custom mode from variables",
+			env: map[string]string{
+				se2.EnvToken:    testAccessKey,
+				se2.EnvMode:     "Custom",
+				se2.EnvAdminURL: srv.URL,
+				se2.EnvExecURL:  srv.URL,
+				se2.EnvTimeout:  "2s",
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "profile from config file",
+			env: map[string]string{
+				se2.EnvConfigFile: configPath,
+				se2.EnvProfile:    "local",
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "variables override profile",
+			env: map[string]string{
+				se2.EnvConfigFile: configPath,
+				se2.EnvProfile:    "broken",
+				se2.EnvAdminURL:   srv.URL,
+				se2.EnvExecURL:    srv.URL,
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "profile without hosts",
+			env: map[string]string{
+				se2.EnvConfigFile: configPath,
+				se2.EnvProfile:    "broken",
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "unknown profile",
+			env: map[string]string{
+				se2.EnvConfigFile: configPath,
+				se2.EnvProfile:    "nope",
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "unknown mode",
+			env: map[string]string{
+				se2.EnvToken: testAccessKey,
+				se2.EnvMode:  "moon",
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "bad timeout",
+			env: map[string]string{
+				se2.EnvToken:   testAccessKey,
+				se2.EnvTimeout: "soon",
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "no token",
+			env:     map[string]string{},
+			wantErr: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, env := range []string{se2.EnvToken, se2.EnvMode, se2.EnvAdminURL, se2.EnvExecURL, se2.EnvTimeout, se2.EnvProfile, se2.EnvConfigFile} {
+				t.Setenv(env, tt.env[env])
+			}
+
+			client, err := se2.NewClientFromEnv()
+			tt.wantErr(t, err)
+
+			if err != nil {
+				return
+			}
+
+			_, err = client.ListTenants(context.Background())
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestNewClientFromProfile(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+
+	err := os.WriteFile(configPath, []byte("profiles:\n  stg:\n    mode: staging\n    token: "+testAccessKey+"\n"), 0o600)
+	require.NoError(t, err)
+
+	t.Setenv(se2.EnvConfigFile, configPath)
+
+	_, err = se2.NewClientFromProfile("stg")
+	assert.NoError(t, err)
+
+	_, err = se2.NewClientFromProfile("prod")
+	assert.ErrorIs(t, err, se2.ErrProfileNotFound)
+}
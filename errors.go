@@ -0,0 +1,152 @@
+package se2
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxErrorBodyBytes caps how much of an unexpected response body we keep around on an APIError.
+	maxErrorBodyBytes = 64 << 10
+
+	// maxErrorMessageLength caps the message taken from a body that is not JSON, like an HTML error page from a proxy.
+	maxErrorMessageLength = 256
+
+	headerRequestID           = "X-Request-Id"
+	headerSuborbitalRequestID = "X-Suborbital-RequestID"
+)
+
+// Sentinel errors that an *APIError matches with errors.Is based on the HTTP status code of the response.
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrServerError  = errors.New("server error")
+)
+
+// APIError is returned by every client method when the SE2 API responds with a status code other than the one the
+// method expects. Use errors.As to get at the details, or errors.Is with one of the sentinel errors, like ErrNotFound,
+// to check for a class of failure.
+type APIError struct {
+	// Method is the name of the client method that made the request, for example "client.GetTenantByName".
+	Method string
+
+	// StatusCode is the HTTP status code of the response, and Expected is the one the method was waiting for.
+	StatusCode int
+	Expected   int
+
+	// Body holds the response body, truncated to 64 KiB, and Truncated is true if the body was longer than that.
+	Body      []byte
+	Truncated bool
+
+	// Message is the error message the server sent back in the body, if there was one.
+	Message string
+
+	// RequestID is the ID of the request the server assigned to it, if it sent one back.
+	RequestID string
+}
+
+// apiErrorBody captures the shapes of the error bodies the SE2 API sends back.
+type apiErrorBody struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	Error   string `json:"error"`
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	var b strings.Builder
+
+	_, _ = fmt.Fprintf(&b, "%s: expected http response code to be %d, got %d", e.Method, e.Expected, e.StatusCode)
+
+	if e.Message != emptyString {
+		_, _ = fmt.Fprintf(&b, ": %s", e.Message)
+	}
+
+	if e.RequestID != emptyString {
+		_, _ = fmt.Fprintf(&b, " (request id %s)", e.RequestID)
+	}
+
+	return b.String()
+}
+
+// Is makes errors.Is work with the sentinel errors for the status code of the response.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServerError:
+		return e.StatusCode >= http.StatusInternalServerError
+	default:
+		return false
+	}
+}
+
+// requestID returns the ID the server assigned to the request from the headers of its response.
+func requestID(res *http.Response) string {
+	id := res.Header.Get(headerRequestID)
+	if id == emptyString {
+		id = res.Header.Get(headerSuborbitalRequestID)
+	}
+
+	return id
+}
+
+// newAPIError creates an *APIError from a response that did not have the expected status code. It reads, but does not
+// close, the response body.
+func newAPIError(method string, expected int, res *http.Response) error {
+	e := &APIError{
+		Method:     method,
+		StatusCode: res.StatusCode,
+		Expected:   expected,
+		RequestID:  requestID(res),
+	}
+
+	// Read a byte past the limit, so we know whether the body was cut off.
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxErrorBodyBytes+1))
+	if err != nil {
+		// We still know the status code, which is the important part.
+		return e
+	}
+
+	if len(body) > maxErrorBodyBytes {
+		body = body[:maxErrorBodyBytes]
+		e.Truncated = true
+	}
+
+	e.Body = body
+
+	var eb apiErrorBody
+
+	if json.Unmarshal(body, &eb) == nil {
+		e.Message = eb.Message
+		if e.Message == emptyString {
+			e.Message = eb.Error
+		}
+	} else {
+		e.Message = strings.TrimSpace(string(body))
+		if len(e.Message) > maxErrorMessageLength {
+			e.Message = e.Message[:maxErrorMessageLength] + "..."
+		}
+	}
+
+	return e
+}
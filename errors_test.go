@@ -0,0 +1,104 @@
+package se2_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+)
+
+func TestAPIError(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		body          string
+		wantIs        error
+		wantMessage   string
+		wantTruncated bool
+	}{
+		{
+			name:        "not found with json message",
+			status:      http.StatusNotFound,
+			body:        `{"status":404,"message":"tenant not found"}`,
+			wantIs:      se2.ErrNotFound,
+			wantMessage: "tenant not found",
+		},
+		{
+			name:        "unauthorized with plain body",
+			status:      http.StatusUnauthorized,
+			body:        "go away\n",
+			wantIs:      se2.ErrUnauthorized,
+			wantMessage: "go away",
+		},
+		{
+			name:        "conflict with error field",
+			status:      http.StatusConflict,
+			body:        `{"error":"tenant already exists"}`,
+			wantIs:      se2.ErrConflict,
+			wantMessage: "tenant already exists",
+		},
+		{
+			name:   "rate limited without body",
+			status: http.StatusTooManyRequests,
+			wantIs: se2.ErrRateLimited,
+		},
+		{
+			name:   "bad gateway",
+			status: http.StatusBadGateway,
+			wantIs: se2.ErrServerError,
+		},
+		{
+			name:        "body at the limit",
+			status:      http.StatusBadGateway,
+			body:        strings.Repeat("x", 64<<10),
+			wantIs:      se2.ErrServerError,
+			wantMessage: strings.Repeat("x", 256) + "...",
+		},
+		{
+			name:          "body over the limit",
+			status:        http.StatusBadGateway,
+			body:          strings.Repeat("x", 64<<10+1),
+			wantIs:        se2.ErrServerError,
+			wantMessage:   strings.Repeat("x", 256) + "...",
+			wantTruncated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Request-Id", "req-1")
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+			require.NoError(t, err)
+
+			_, err = client.GetTenantByName(context.Background(), "acme")
+			require.Error(t, err)
+
+			assert.ErrorIs(t, err, tt.wantIs)
+			assert.NotErrorIs(t, err, se2.ErrForbidden)
+
+			var apiErr *se2.APIError
+			require.True(t, errors.As(err, &apiErr))
+
+			assert.Equal(t, "client.GetTenantByName", apiErr.Method)
+			assert.Equal(t, tt.status, apiErr.StatusCode)
+			assert.Equal(t, http.StatusOK, apiErr.Expected)
+			assert.Equal(t, tt.wantMessage, apiErr.Message)
+			assert.Equal(t, "req-1", apiErr.RequestID)
+			assert.Equal(t, tt.wantTruncated, apiErr.Truncated)
+			assert.Equal(t, []byte(tt.body)[:min(len(tt.body), 64<<10)], apiErr.Body)
+		})
+	}
+}
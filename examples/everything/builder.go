@@ -142,8 +142,15 @@ export const run = (input) => {
 
 	fmt.Printf("tenant %s has the following plugins:\n\n%#v\n\n", sessionTenant.Name, plugins)
 
-	printHeader("sleep for 8 seconds to wait until module gets propagated")
-	time.Sleep(8 * time.Second)
+	printHeader("wait until the promoted version of the plugin is being served")
+
+	err = client.WaitForPluginReady(buildCtx, sessionTenant.Name, namespace, pluginName, promotionResult.Ref, se2.WaitOptions{
+		Timeout: time.Minute,
+		Probe:   true,
+	})
+	if err != nil {
+		log.Fatalf("client.WaitForPluginReady: %s", err.Error())
+	}
 
 	printHeader("execution")
 	exec, err := client.Exec(buildCtx, []byte(`uh hi`), sessionTenant.Name, namespace, pluginName)
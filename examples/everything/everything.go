@@ -10,12 +10,13 @@ import (
 )
 
 func main() {
-	token, ok := os.LookupEnv("EVERYTHING_TOKEN")
+	token, ok := os.LookupEnv(se2.EnvToken)
 	if !ok {
-		log.Fatalf("There is no token set. Get an environment access key from the se2 admin area, and set it to the EVERYTHING_TOKEN env var.")
+		log.Fatalf("There is no token set. Get an environment access key from the se2 admin area, and set it to the %s env var.", se2.EnvToken)
 	}
 
-	// Set up the client to point at staging (admin and builder) with a valid access token.
+	// Set up the client to point at staging (admin and builder) with a valid access token. This example creates tenants,
+	// and builds and promotes plugins, so it never runs against production.
 	client, err := se2.NewClient(se2.ModeStaging, token)
 	if err != nil {
 		log.Fatalf("encountered new client error: %s", err.Error())
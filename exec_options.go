@@ -0,0 +1,171 @@
+package se2
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	headerContentType    = "Content-Type"
+	headerIdempotencyKey = "Idempotency-Key"
+)
+
+// ExecOption configures a single plugin execution. They are accepted by every exec method.
+type ExecOption func(*execOptions)
+
+// execOptions holds the configuration of a single execution.
+type execOptions struct {
+	header  http.Header
+	query   url.Values
+	timeout time.Duration
+	noCache bool
+
+	responseHeader http.Header
+
+	maxRequestBytes  int64
+	maxResponseBytes int64
+}
+
+// newExecOptions applies the options on top of the defaults.
+func newExecOptions(opts []ExecOption) execOptions {
+	o := execOptions{
+		header: make(http.Header),
+		query:  make(url.Values),
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// ExecHeader adds a header to the request, which the plugin can read. It can be passed multiple times, also for the
+// same key. The Authorization header can't be overridden.
+func ExecHeader(key, value string) ExecOption {
+	return func(o *execOptions) {
+		o.header.Add(key, value)
+	}
+}
+
+// ExecContentType sets the Content-Type header of the request.
+func ExecContentType(contentType string) ExecOption {
+	return func(o *execOptions) {
+		o.header.Set(headerContentType, contentType)
+	}
+}
+
+// ExecQuery adds a query parameter to the request URL, which the plugin can read. It can be passed multiple times, also
+// for the same key.
+func ExecQuery(key, value string) ExecOption {
+	return func(o *execOptions) {
+		o.query.Add(key, value)
+	}
+}
+
+// ExecTimeout limits how long the execution can take, including reading the response. It's applied on top of the
+// context passed to the exec method, and the timeout of the http client, whichever is shortest wins.
+func ExecTimeout(timeout time.Duration) ExecOption {
+	return func(o *execOptions) {
+		o.timeout = timeout
+	}
+}
+
+// ExecIdempotencyKey sets the Idempotency-Key header of the request. It tells the client and the server that the
+// request can be safely repeated, so executions with a key are retried like the read-only calls are, see RetryPolicy.
+func ExecIdempotencyKey(key string) ExecOption {
+	return func(o *execOptions) {
+		o.header.Set(headerIdempotencyKey, key)
+	}
+}
+
+// ExecNoCache makes Exec skip the exec cache, see WithExecCache. The plugin is always run, and its output is not cached.
+func ExecNoCache() ExecOption {
+	return func(o *execOptions) {
+		o.noCache = true
+	}
+}
+
+// ExecResponseHeader copies the headers of the response of the plugin into dst, whether the execution succeeded or not.
+// Use it with ExecStream to pass the headers on, or with Exec to read metadata like the content type of the output.
+func ExecResponseHeader(dst http.Header) ExecOption {
+	return func(o *execOptions) {
+		o.responseHeader = dst
+	}
+}
+
+// ExecMaxRequestBytes limits the size of the payload of this execution, overriding WithMaxRequestBytes.
+func ExecMaxRequestBytes(limit int64) ExecOption {
+	return func(o *execOptions) {
+		o.maxRequestBytes = limit
+	}
+}
+
+// ExecMaxResponseBytes limits the size of the output of this execution, overriding WithMaxExecResponseBytes and
+// WithMaxResponseBytes. Unlike those, it applies to ExecStream too, whose returned reader fails once the limit is
+// crossed.
+func ExecMaxResponseBytes(limit int64) ExecOption {
+	return func(o *execOptions) {
+		o.maxResponseBytes = limit
+	}
+}
+
+// apply adds the headers and the query parameters to the request.
+func (o execOptions) apply(req *http.Request) {
+	for key, values := range o.header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	if len(o.query) > 0 {
+		q := req.URL.Query()
+
+		for key, values := range o.query {
+			for _, value := range values {
+				q.Add(key, value)
+			}
+		}
+
+		req.URL.RawQuery = q.Encode()
+	}
+}
+
+// captureHeader copies the headers of the response to the destination of ExecResponseHeader, if it was passed.
+func (o execOptions) captureHeader(res *http.Response) {
+	if o.responseHeader == nil {
+		return
+	}
+
+	for key, values := range res.Header {
+		o.responseHeader[key] = append(o.responseHeader[key], values...)
+	}
+}
+
+// withTimeout returns the context with the timeout applied if there is one. The cancel function is never nil.
+func (o execOptions) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, o.timeout)
+}
+
+// cancelBody cancels the context of a request once its response body is closed, so a per-call timeout covers reading
+// a streamed response without leaking the timer.
+type cancelBody struct {
+	io.ReadCloser
+
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels the context.
+func (b cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+
+	return err //nolint:wrapcheck
+}
@@ -0,0 +1,169 @@
+package se2
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ExecResult is the outcome of a plugin execution with everything the edge sent back, and how long it took.
+type ExecResult struct {
+	// Body is the output of the plugin.
+	Body []byte
+
+	// StatusCode and Header are the status code and the headers of the response. They are also set when the plugin
+	// failed, along with RequestID and Timing, but Body is not; the *APIError holds it.
+	StatusCode int
+	Header     http.Header
+
+	// RequestID is the ID the server assigned to the request, if it sent one back.
+	RequestID string
+
+	// Timing is how long the parts of the request took, measured by the client.
+	Timing ExecTiming
+}
+
+// ExecTiming breaks down how long an execution took, as measured by the client. If the request was retried, it covers
+// the last attempt, except for Total.
+type ExecTiming struct {
+	// Connect is how long it took to get a connection to the edge, including DNS lookup, dialing, and the TLS
+	// handshake. It's close to zero if an idle connection was reused, see ConnReused.
+	Connect time.Duration
+
+	// TTFB is the time to first byte: from the request being fully sent until the first byte of the response arrived.
+	// It's a good measure of how long the plugin ran for.
+	TTFB time.Duration
+
+	// Total is the time from the start of the call until the whole response was read.
+	Total time.Duration
+
+	// ConnReused is true if the request was sent over a connection that was already open.
+	ConnReused bool
+}
+
+// ExecDetailed runs the plugin with the payload as input, and returns the response headers, status, server metadata,
+// and the client-measured latency of the request along with the output, which is useful when debugging slow plugins.
+// Unlike Exec, it always sends a single request to the edge: it does not use the exec cache, the circuit breaker, or
+// hedging, so the result describes a real execution. If the plugin fails, the error is an *APIError, and the result
+// still has the status code, headers, request ID, and timing of the response.
+func (c *Client) ExecDetailed(ctx context.Context, payload []byte, ident, namespace, plugin string, opts ...ExecOption) (_ ExecResult, err error) {
+	ctx, span := c.startSpan(ctx, Operation{Name: "client.ExecDetailed", Tenant: ident, Namespace: namespace, Plugin: plugin})
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	start := time.Now()
+	timer := &execTimer{}
+
+	header := newExecOptions(opts).responseHeader
+	if header == nil {
+		header = make(http.Header)
+		opts = append(opts[:len(opts):len(opts)], ExecResponseHeader(header))
+	}
+
+	res, err := c.exec(httptrace.WithClientTrace(ctx, timer.clientTrace()), "client.ExecDetailed", bytes.NewReader(payload), ident, namespace, plugin, opts)
+	if err != nil {
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			return ExecResult{}, err
+		}
+
+		timing := timer.timing()
+		timing.Total = time.Since(start)
+
+		return ExecResult{
+			StatusCode: apiErr.StatusCode,
+			Header:     header,
+			RequestID:  apiErr.RequestID,
+			Timing:     timing,
+		}, err
+	}
+
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	b, err := readResponse(res, c.execResponseLimit(newExecOptions(opts)))
+	if err != nil {
+		return ExecResult{}, errors.Wrap(err, "client.ExecDetailed: readResponse")
+	}
+
+	timing := timer.timing()
+	timing.Total = time.Since(start)
+
+	return ExecResult{
+		Body:       b,
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		RequestID:  requestID(res),
+		Timing:     timing,
+	}, nil
+}
+
+// execTimer collects the timestamps of a request from the httptrace hooks. The hooks can be called from other
+// goroutines than the one making the request, hence the lock.
+type execTimer struct {
+	mu sync.Mutex
+
+	getConn   time.Time
+	gotConn   time.Time
+	wrote     time.Time
+	firstByte time.Time
+	reused    bool
+}
+
+// clientTrace returns the httptrace hooks that record into the timer.
+func (t *execTimer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			t.getConn = time.Now()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			t.gotConn = time.Now()
+			t.reused = info.Reused
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			t.wrote = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			t.firstByte = time.Now()
+		},
+	}
+}
+
+// timing returns the durations between the recorded timestamps. Total is left for the caller to fill in.
+func (t *execTimer) timing() ExecTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var et ExecTiming
+
+	et.ConnReused = t.reused
+
+	if !t.getConn.IsZero() && !t.gotConn.IsZero() {
+		et.Connect = t.gotConn.Sub(t.getConn)
+	}
+
+	if !t.wrote.IsZero() && !t.firstByte.IsZero() {
+		et.TTFB = t.firstByte.Sub(t.wrote)
+	}
+
+	return et
+}
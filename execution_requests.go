@@ -6,39 +6,248 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"sync"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
-	pathExec = "/name/%s/%s/%s"
+	pathExec    = "/name/%s/%s/%s"
+	pathExecRef = "/ref/%s"
 )
 
+// ErrNoRef is returned by ExecRef when the ref is empty.
+var ErrNoRef = errors.New("plugin ref is empty")
+
 // Exec takes a context, a byte slice payload, an ident, namespace, and plugin triad to identify the plugin to run with
-// the payload as input. It returns a byte slice as output, and an error if something went wrong.
-func (c *Client) Exec(ctx context.Context, payload []byte, ident, namespace, plugin string) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf(c.execHost+pathExec, ident, namespace, plugin), bytes.NewReader(payload))
+// the payload as input. It returns a byte slice as output, and an error if something went wrong. Use ExecOption values
+// to add headers, a content type, or query parameters to the request, or to set a timeout. The output of plugins
+// cached with WithExecCache may be served from the cache, plugins hedged with WithHedging may be run twice, and plugins
+// whose circuit breaker is open are not run at all, see WithCircuitBreaker.
+func (c *Client) Exec(ctx context.Context, payload []byte, ident, namespace, plugin string, opts ...ExecOption) (_ []byte, err error) {
+	ctx, span := c.startSpan(ctx, Operation{Name: "client.Exec", Tenant: ident, Namespace: namespace, Plugin: plugin})
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	target := ExecTarget{Ident: ident, Namespace: namespace, Plugin: plugin}
+
+	var ref string
+
+	hash, cacheable := c.execCache.hash(target, payload, opts)
+	if cacheable {
+		var (
+			b  []byte
+			ok bool
+		)
+
+		b, ref, ok = c.execCache.get(target, hash)
+		if ok {
+			span.SetAttributes(attribute.Bool("se2.cache_hit", true))
+
+			return b, nil
+		}
+	}
+
+	breaker, guarded := c.breakers.get(target)
+	if guarded && !breaker.allow() {
+		span.SetAttributes(attribute.Bool("se2.circuit_open", true))
+
+		return breaker.open(ctx, payload)
+	}
+
+	var b []byte
+
+	if w, ok := c.hedger.window(target); ok {
+		b, err = c.execHedged(ctx, w, payload, target, opts)
+	} else {
+		b, err = c.execOnce(ctx, payload, target, opts)
+	}
+
+	if guarded {
+		breaker.record(err)
+	}
+
 	if err != nil {
-		return nil, errors.Wrap(err, "client.Exec: http.NewRequest")
+		return nil, err
 	}
 
-	res, err := c.do(req)
+	if cacheable {
+		c.execCache.put(target, ref, hash, b)
+	}
+
+	return b, nil
+}
+
+// execOnce sends a single execution request for Exec, and returns the output.
+func (c *Client) execOnce(ctx context.Context, payload []byte, target ExecTarget, opts []ExecOption) ([]byte, error) {
+	res, err := c.exec(ctx, "client.Exec", bytes.NewReader(payload), target.Ident, target.Namespace, target.Plugin, opts)
 	if err != nil {
-		return nil, errors.Wrap(err, "client.Exec: c.do")
+		return nil, err
 	}
 
 	defer func() {
 		_ = res.Body.Close()
 	}()
 
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf(httpResponseCodeErrorFormat, "client.Exec", http.StatusOK, res.StatusCode)
+	b, err := readResponse(res, c.execResponseLimit(newExecOptions(opts)))
+	if err != nil {
+		return nil, errors.Wrap(err, "client.Exec: readResponse")
+	}
+
+	return b, nil
+}
+
+// ExecStream works like Exec, except that the input is streamed to the plugin from the reader, and the output is
+// streamed back in the returned io.ReadCloser, so neither has to fit in memory. The caller must close the returned
+// reader. If input is also an io.Closer, it's closed once it has been sent, or when ExecStream fails.
+//
+// The input is read only once, so the request is not retried, even for readers that could be rewound, like a
+// *bytes.Reader.
+func (c *Client) ExecStream(ctx context.Context, input io.Reader, ident, namespace, plugin string, opts ...ExecOption) (io.ReadCloser, error) {
+	// The span lasts until the output is closed, so it covers reading it.
+	ctx, span := c.startSpan(ctx, Operation{Name: "client.ExecStream", Tenant: ident, Namespace: namespace, Plugin: plugin})
+
+	body := &streamInput{Reader: input}
+
+	res, err := c.exec(ctx, "client.ExecStream", body, ident, namespace, plugin, opts)
+	if err != nil {
+		_ = body.Close()
+
+		endSpan(span, err)
+
+		return nil, err
 	}
 
-	b, err := io.ReadAll(res.Body)
+	err = limitResponse(res, newExecOptions(opts).maxResponseBytes)
 	if err != nil {
-		return nil, errors.Wrap(err, "client.Exec: io.ReadAll(res.Body)")
+		_ = res.Body.Close()
+
+		err = errors.Wrap(err, "client.ExecStream")
+		endSpan(span, err)
+
+		return nil, err
+	}
+
+	return &spanBody{ReadCloser: res.Body, span: span}, nil
+}
+
+// streamInput is the body of an ExecStream request. It hides the type of the input from http.NewRequest, which would
+// otherwise make bytes and strings readers rewindable, and so retried, and closes the input at most once, whether that
+// is the http client after sending it, or ExecStream after failing before it got that far.
+type streamInput struct {
+	io.Reader
+
+	once sync.Once
+}
+
+// Close closes the input if it's an io.Closer. Only the first call does.
+func (s *streamInput) Close() error {
+	var err error
+
+	s.once.Do(func() {
+		if closer, ok := s.Reader.(io.Closer); ok {
+			err = closer.Close()
+		}
+	})
+
+	return err //nolint:wrapcheck
+}
+
+// ExecRef runs the exact version of a plugin identified by its ref, like the one returned by PromotePluginDraft or the
+// Ref field of a Plugin, with the payload as input. Unlike Exec, which runs whichever version is live, the version that
+// runs does not change when the plugin is promoted again, which makes it useful to test a specific build, or to keep
+// callers on a known version during a rollout.
+func (c *Client) ExecRef(ctx context.Context, payload []byte, ref string, opts ...ExecOption) (_ []byte, err error) {
+	if ref == emptyString {
+		return nil, errors.Wrap(ErrNoRef, "client.ExecRef")
+	}
+
+	if !validSegment(ref) {
+		return nil, errors.Wrapf(ErrInvalidFQMN, "client.ExecRef: ref %q is not valid", ref)
+	}
+
+	op := Operation{Name: "client.ExecRef", Ref: ref}
+
+	ctx, span := c.startSpan(ctx, op)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	res, err := c.execPath(ctx, op, fmt.Sprintf(pathExecRef, url.PathEscape(ref)), bytes.NewReader(payload), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	b, err := readResponse(res, c.execResponseLimit(newExecOptions(opts)))
+	if err != nil {
+		return nil, errors.Wrap(err, "client.ExecRef: readResponse")
 	}
 
 	return b, nil
 }
+
+// exec sends the body to the plugin identified by the ident, namespace, and plugin triad on behalf of the named client
+// method, with the exec options applied. It returns the response if it was successful, in which case the caller needs
+// to close its body. Errors are already prefixed with the name of the method. The triad is validated like an FQMN, as
+// it's put in the path of the request as is.
+func (c *Client) exec(ctx context.Context, method string, body io.Reader, ident, namespace, plugin string, opts []ExecOption) (*http.Response, error) {
+	op := Operation{Name: method, Tenant: ident, Namespace: namespace, Plugin: plugin}
+
+	err := FQMN{Tenant: ident, Namespace: namespace, Plugin: plugin}.Validate()
+	if err != nil {
+		return nil, errors.Wrap(err, method)
+	}
+
+	return c.execPath(ctx, op, fmt.Sprintf(pathExec, ident, namespace, plugin), body, opts)
+}
+
+// execPath is exec for any path on the exec host, on behalf of the operation.
+func (c *Client) execPath(ctx context.Context, op Operation, path string, body io.Reader, opts []ExecOption) (*http.Response, error) {
+	o := newExecOptions(opts)
+	method := op.Name
+
+	limitedBody, err := limitRequest(body, c.execRequestLimit(o))
+	if err != nil {
+		return nil, errors.Wrap(err, method)
+	}
+
+	execCtx, cancel := o.withTimeout(withExecHost(withOperation(ctx, op)))
+
+	req, err := http.NewRequestWithContext(execCtx, http.MethodPost, c.execHost+path, limitedBody)
+	if err != nil {
+		cancel()
+
+		return nil, errors.Wrap(err, method+": http.NewRequest")
+	}
+
+	o.apply(req)
+
+	res, err := c.do(req)
+	if err != nil {
+		cancel()
+
+		return nil, errors.Wrap(err, method+": c.do")
+	}
+
+	o.captureHeader(res)
+
+	if res.StatusCode != http.StatusOK {
+		defer func() {
+			_ = res.Body.Close()
+			cancel()
+		}()
+
+		return nil, newAPIError(method, http.StatusOK, res)
+	}
+
+	res.Body = cancelBody{ReadCloser: res.Body, cancel: cancel}
+
+	return res, nil
+}
@@ -0,0 +1,327 @@
+package se2_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+)
+
+// echoServer returns a server that streams the request body back, upper cased.
+func echoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		_, _ = w.Write([]byte(strings.ToUpper(string(b))))
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestClient_ExecStream(t *testing.T) {
+	srv := echoServer(t)
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	out, err := client.ExecStream(context.Background(), strings.NewReader(strings.Repeat("a", 1<<20)), "acme", "default", "upper")
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(out)
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+
+	assert.Equal(t, strings.Repeat("A", 1<<20), string(b))
+}
+
+// closeCounter is an input that counts how often it was closed.
+type closeCounter struct {
+	io.Reader
+
+	closed int
+}
+
+func (c *closeCounter) Close() error {
+	c.closed++
+
+	return nil
+}
+
+func TestClient_ExecStream_NotRetried(t *testing.T) {
+	var requests int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		requests++
+
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL, srv.URL),
+		se2.WithRetryPolicy(se2.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+	)
+	require.NoError(t, err)
+
+	// A strings reader could be rewound, but a stream is still sent only once.
+	_, err = client.ExecStream(context.Background(), strings.NewReader(`hi`), "acme", "default", "upper")
+	assert.ErrorIs(t, err, se2.ErrRateLimited)
+	assert.Equal(t, 1, requests)
+}
+
+func TestClient_ExecStream_ClosesInput(t *testing.T) {
+	srv := echoServer(t)
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	sent := &closeCounter{Reader: strings.NewReader(`hi`)}
+
+	out, err := client.ExecStream(context.Background(), sent, "acme", "default", "upper")
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+	assert.Equal(t, 1, sent.closed)
+
+	refused := &closeCounter{Reader: strings.NewReader(`hi`)}
+
+	_, err = client.ExecStream(context.Background(), refused, "acme", "..", "upper")
+	assert.ErrorIs(t, err, se2.ErrInvalidFQMN)
+	assert.Equal(t, 1, refused.closed, "closed even though it was never sent")
+}
+
+func TestWithMaxExecResponseBytes(t *testing.T) {
+	srv := echoServer(t)
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL, srv.URL),
+		se2.WithMaxExecResponseBytes(4),
+	)
+	require.NoError(t, err)
+
+	out, err := client.Exec(context.Background(), []byte(`abcd`), "acme", "default", "upper")
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`ABCD`), out)
+
+	_, err = client.Exec(context.Background(), []byte(`abcde`), "acme", "default", "upper")
+	assert.ErrorIs(t, err, se2.ErrPayloadTooLarge)
+}
+
+func TestClient_ExecDetailed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+
+		w.Header().Set("X-Request-Id", "req-7")
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(`done`))
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	result, err := client.ExecDetailed(context.Background(), []byte(`go`), "acme", "default", "slow")
+	require.NoError(t, err)
+
+	assert.Equal(t, []byte(`done`), result.Body)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "text/plain", result.Header.Get("Content-Type"))
+	assert.Equal(t, "req-7", result.RequestID)
+	assert.GreaterOrEqual(t, result.Timing.TTFB, 20*time.Millisecond)
+	assert.GreaterOrEqual(t, result.Timing.Total, result.Timing.TTFB)
+	assert.False(t, result.Timing.ConnReused)
+}
+
+func TestClient_ExecDetailed_Failure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-8")
+		w.Header().Set("X-Plugin-Trace", "step 3")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte(`bad input`))
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	result, err := client.ExecDetailed(context.Background(), []byte(`go`), "acme", "default", "strict")
+
+	var apiErr *se2.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, []byte(`bad input`), apiErr.Body)
+
+	assert.Nil(t, result.Body)
+	assert.Equal(t, http.StatusUnprocessableEntity, result.StatusCode)
+	assert.Equal(t, "step 3", result.Header.Get("X-Plugin-Trace"))
+	assert.Equal(t, "req-8", result.RequestID)
+	assert.Positive(t, result.Timing.Total)
+}
+
+func TestExecOptions(t *testing.T) {
+	var attempts int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, []string{"a", "b"}, r.Header.Values("X-Custom"))
+		assert.Equal(t, "Bearer "+testAccessKey, r.Header.Get("Authorization"))
+		assert.Equal(t, "key-1", r.Header.Get("Idempotency-Key"))
+		assert.Equal(t, "1", r.URL.Query().Get("page"))
+
+		b, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, `{}`, string(b))
+
+		if attempts == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+
+			return
+		}
+
+		if r.URL.Query().Get("slow") != "" {
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		_, _ = w.Write([]byte(`ok`))
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL, srv.URL),
+		se2.WithRetryPolicy(se2.RetryPolicy{MaxAttempts: 2}),
+	)
+	require.NoError(t, err)
+
+	opts := []se2.ExecOption{
+		se2.ExecContentType("application/json"),
+		se2.ExecHeader("X-Custom", "a"),
+		se2.ExecHeader("X-Custom", "b"),
+		se2.ExecHeader("Authorization", "Bearer nope"),
+		se2.ExecQuery("page", "1"),
+		se2.ExecIdempotencyKey("key-1"),
+	}
+
+	// The idempotency key makes the 502 retryable.
+	out, err := client.Exec(context.Background(), []byte(`{}`), "acme", "default", "hello", opts...)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`ok`), out)
+	assert.Equal(t, 2, attempts)
+
+	_, err = client.Exec(context.Background(), []byte(`{}`), "acme", "default", "hello",
+		append(opts, se2.ExecQuery("slow", "yes"), se2.ExecTimeout(20*time.Millisecond))...)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClient_ExecRef(t *testing.T) {
+	var gotPath string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+
+		_, _ = w.Write([]byte(`pinned`))
+	}))
+	defer srv.Close()
+
+	var gotOp se2.Operation
+
+	capture := func(next se2.Doer) se2.Doer {
+		return se2.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			gotOp, _ = se2.OperationFromContext(req.Context())
+
+			return next.Do(req)
+		})
+	}
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL), se2.WithMiddleware(capture))
+	require.NoError(t, err)
+
+	out, err := client.ExecRef(context.Background(), []byte(`hello`), "98qhrfgo3089")
+	require.NoError(t, err)
+
+	assert.Equal(t, []byte(`pinned`), out)
+	assert.Equal(t, "/ref/98qhrfgo3089", gotPath)
+	assert.Equal(t, se2.Operation{Name: "client.ExecRef", Ref: "98qhrfgo3089"}, gotOp)
+
+	_, err = client.ExecRef(context.Background(), []byte(`hello`), "")
+	assert.ErrorIs(t, err, se2.ErrNoRef)
+
+	_, err = client.ExecRef(context.Background(), []byte(`hello`), "..")
+	assert.ErrorIs(t, err, se2.ErrInvalidFQMN)
+}
+
+func TestClient_Exec_InvalidNames(t *testing.T) {
+	var requests int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		ident     string
+		namespace string
+		plugin    string
+	}{
+		{name: "dot dot in namespace", ident: "attacker", namespace: "x/../../victim/ns", plugin: "plugin"},
+		{name: "dot plugin", ident: "acme", namespace: "default", plugin: "."},
+		{name: "empty namespace level", ident: "acme", namespace: "api//users", plugin: "add"},
+		{name: "slash in tenant", ident: "acme/other", namespace: "default", plugin: "hello"},
+		{name: "query in plugin", ident: "acme", namespace: "default", plugin: "hello?x=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.Exec(context.Background(), []byte(`hi`), tt.ident, tt.namespace, tt.plugin)
+			assert.ErrorIs(t, err, se2.ErrInvalidFQMN)
+
+			_, err = client.ExecStream(context.Background(), strings.NewReader(`hi`), tt.ident, tt.namespace, tt.plugin)
+			assert.ErrorIs(t, err, se2.ErrInvalidFQMN)
+		})
+	}
+
+	assert.Zero(t, requests)
+}
+
+func TestExecResponseHeader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Suborbital-Ref", "abc")
+
+		if r.URL.Path == "/name/acme/default/broken" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	header := make(http.Header)
+
+	_, err = client.Exec(context.Background(), []byte(`hi`), "acme", "default", "hello", se2.ExecResponseHeader(header))
+	require.NoError(t, err)
+	assert.Equal(t, "abc", header.Get("X-Suborbital-Ref"))
+
+	header = make(http.Header)
+
+	_, err = client.Exec(context.Background(), []byte(`hi`), "acme", "default", "broken", se2.ExecResponseHeader(header))
+	require.ErrorIs(t, err, se2.ErrServerError)
+	assert.Equal(t, "abc", header.Get("X-Suborbital-Ref"), "captured on failures too")
+}
@@ -0,0 +1,387 @@
+// Package extension lets a host application declare typed extension points that tenants customize with plugins.
+//
+// An extension point has a name and a default Go implementation. When it's run for a tenant, the registry looks up
+// whether the tenant has a plugin for it with GetPlugins, and runs that plugin, or the default if there is none:
+//
+//	registry := extension.NewRegistry(client)
+//
+//	discount, err := extension.Declare(registry, "discount", defaultDiscount, extension.Timeout(time.Second))
+//	if err != nil {
+//		// handle error
+//	}
+//
+//	quote, err := discount.Run(ctx, tenant, order)
+package extension
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/se2-go"
+)
+
+const (
+	defaultNamespace       = "default"
+	defaultTimeout         = 5 * time.Second
+	defaultRefreshInterval = 30 * time.Second
+	refreshTimeout         = 10 * time.Second
+	minRefreshBackoff      = time.Second
+)
+
+// ErrDuplicatePoint is returned by Declare when the registry already has a point with the same name.
+var ErrDuplicatePoint = errors.New("extension point is already declared")
+
+// Registry holds the extension points of the host application, and the plugins of the tenants it looked up.
+type Registry struct {
+	client  *se2.Client
+	refresh time.Duration
+
+	mu      sync.Mutex
+	points  map[string]Info
+	tenants map[string]*tenantPlugins
+}
+
+// tenantPlugins is the last list of plugins GetPlugins returned for a tenant, and the state of refreshing it. It's
+// guarded by the lock of the registry.
+type tenantPlugins struct {
+	// plugins is the last list, and fetched when it arrived. fetched is zero until the first list arrives.
+	plugins map[pluginKey]struct{}
+	fetched time.Time
+
+	// err is the error of the last refresh, if it failed, and failures the number of refreshes that failed in a row.
+	// No refresh is started before retryAt.
+	err      error
+	failures int
+	retryAt  time.Time
+
+	// refreshing is closed when the refresh in flight is done, or nil if there is none.
+	refreshing chan struct{}
+}
+
+// pluginKey identifies a plugin of a tenant.
+type pluginKey struct {
+	namespace string
+	plugin    string
+}
+
+// RegistryOption configures a Registry.
+type RegistryOption func(*Registry)
+
+// WithRefreshInterval sets how long the plugins of a tenant are remembered before GetPlugins is called again. Defaults
+// to 30 seconds. Refreshes happen in the background, while the plugins from before keep being used. If a refresh
+// fails, the next one waits a second, then twice as long after every failure in a row, up to the refresh interval if
+// that's longer.
+func WithRefreshInterval(interval time.Duration) RegistryOption {
+	return func(r *Registry) {
+		r.refresh = interval
+	}
+}
+
+// NewRegistry returns an empty registry that looks up and runs plugins with the client.
+func NewRegistry(client *se2.Client, opts ...RegistryOption) *Registry {
+	r := &Registry{
+		client:  client,
+		refresh: defaultRefreshInterval,
+		points:  make(map[string]Info),
+		tenants: make(map[string]*tenantPlugins),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Points returns the declared extension points, sorted by name.
+func (r *Registry) Points() []Info {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	infos := make([]Info, 0, len(r.points))
+	for _, info := range r.points {
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+
+	return infos
+}
+
+// Forget drops the plugins remembered for the tenant, so the next run of a point looks them up again, for example after
+// the tenant promoted a plugin.
+func (r *Registry) Forget(tenant string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.tenants, tenant)
+}
+
+// hasPlugin reports whether the tenant has the plugin. If the plugins of the tenant are known, it answers right away,
+// and refreshes them in the background once they are older than the refresh interval. Otherwise it waits for GetPlugins,
+// but no longer than ctx allows. Concurrent lookups of the same tenant share a single GetPlugins call, and a tenant
+// whose plugins could not be listed gets the same error back until the backoff is over.
+func (r *Registry) hasPlugin(ctx context.Context, tenant string, key pluginKey) (bool, error) {
+	r.mu.Lock()
+
+	tp, ok := r.tenants[tenant]
+	if !ok {
+		tp = &tenantPlugins{}
+		r.tenants[tenant] = tp
+	}
+
+	now := time.Now()
+
+	if !tp.fetched.IsZero() {
+		if now.Sub(tp.fetched) >= r.refresh && !now.Before(tp.retryAt) && tp.refreshing == nil {
+			r.startRefresh(ctx, tenant, tp)
+		}
+
+		_, found := tp.plugins[key]
+		r.mu.Unlock()
+
+		return found, nil
+	}
+
+	if tp.err != nil && now.Before(tp.retryAt) {
+		err := tp.err
+		r.mu.Unlock()
+
+		return false, err
+	}
+
+	done := tp.refreshing
+	if done == nil {
+		done = r.startRefresh(ctx, tenant, tp)
+	}
+
+	r.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return false, errors.Wrap(ctx.Err(), "client.GetPlugins")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if tp.fetched.IsZero() {
+		return false, tp.err
+	}
+
+	_, found := tp.plugins[key]
+
+	return found, nil
+}
+
+// startRefresh calls GetPlugins for the tenant in the background, and returns a channel that is closed once it's done.
+// The call keeps the values of ctx, but not its deadline, as it's shared by every lookup of the tenant until it's done.
+// It's called with the lock of the registry held.
+func (r *Registry) startRefresh(ctx context.Context, tenant string, tp *tenantPlugins) chan struct{} {
+	done := make(chan struct{})
+	tp.refreshing = done
+
+	go func() {
+		defer close(done)
+
+		refreshCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refreshTimeout)
+		defer cancel()
+
+		res, err := r.client.GetPlugins(refreshCtx, tenant)
+		if errors.Is(err, se2.ErrNotFound) {
+			// A tenant that does not exist has no plugins yet, so it gets the defaults.
+			res, err = se2.PluginResponse{}, nil
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		tp.refreshing = nil
+
+		if err != nil {
+			tp.err = errors.Wrap(err, "client.GetPlugins")
+			tp.failures++
+			tp.retryAt = time.Now().Add(r.backoff(tp.failures))
+
+			return
+		}
+
+		tp.plugins = make(map[pluginKey]struct{}, len(res.Plugins))
+		for _, p := range res.Plugins {
+			tp.plugins[pluginKey{namespace: p.Namespace, plugin: p.Name}] = struct{}{}
+		}
+
+		tp.fetched = time.Now()
+		tp.err = nil
+		tp.failures = 0
+		tp.retryAt = time.Time{}
+	}()
+
+	return done
+}
+
+// backoff returns how long to wait before refreshing the plugins of a tenant after the given number of failed refreshes
+// in a row: a second, doubling with every failure, up to the refresh interval if that's longer.
+func (r *Registry) backoff(failures int) time.Duration {
+	wait := minRefreshBackoff
+	for i := 1; i < failures && wait < r.refresh; i++ {
+		wait *= 2
+	}
+
+	if wait > r.refresh && r.refresh > minRefreshBackoff {
+		wait = r.refresh
+	}
+
+	return wait
+}
+
+// Info describes a declared extension point.
+type Info struct {
+	// Name is the name of the point, and Namespace and Plugin the plugin of a tenant that implements it.
+	Name      string
+	Namespace string
+	Plugin    string
+
+	// Timeout is how long a run of the point can take.
+	Timeout time.Duration
+}
+
+// PointOption configures an extension point.
+type PointOption func(*pointOptions)
+
+// pointOptions holds the configuration of an extension point.
+type pointOptions struct {
+	info  Info
+	codec se2.Codec
+}
+
+// Namespace sets the namespace of the plugins that implement the point. Defaults to "default".
+func Namespace(namespace string) PointOption {
+	return func(o *pointOptions) {
+		o.info.Namespace = namespace
+	}
+}
+
+// Plugin sets the name of the plugins that implement the point. Defaults to the name of the point.
+func Plugin(plugin string) PointOption {
+	return func(o *pointOptions) {
+		o.info.Plugin = plugin
+	}
+}
+
+// Timeout limits how long a run of the point can take, whether it runs the plugin or the default. Defaults to 5
+// seconds, which is also used if the timeout is not positive.
+func Timeout(timeout time.Duration) PointOption {
+	return func(o *pointOptions) {
+		o.info.Timeout = timeout
+	}
+}
+
+// Codec sets how the input and output of the plugins are encoded. Defaults to se2.JSONCodec.
+func Codec(codec se2.Codec) PointOption {
+	return func(o *pointOptions) {
+		o.codec = codec
+	}
+}
+
+// DefaultFunc is the Go implementation of an extension point, used for tenants that have no plugin for it.
+type DefaultFunc[In, Out any] func(ctx context.Context, in In) (Out, error)
+
+// Point is a typed extension point, see Declare.
+type Point[In, Out any] struct {
+	registry *Registry
+	info     Info
+	codec    se2.Codec
+	def      DefaultFunc[In, Out]
+}
+
+// Declare adds an extension point to the registry. A tenant implements it with a plugin in the namespace and with the
+// name set by the options, which gets the input encoded with the codec, and returns its output in the same encoding.
+// It returns ErrDuplicatePoint if the registry already has a point with the name.
+func Declare[In, Out any](r *Registry, name string, def DefaultFunc[In, Out], opts ...PointOption) (*Point[In, Out], error) {
+	o := pointOptions{
+		info: Info{
+			Name:      name,
+			Namespace: defaultNamespace,
+			Plugin:    name,
+			Timeout:   defaultTimeout,
+		},
+		codec: se2.JSONCodec{},
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.info.Timeout <= 0 {
+		o.info.Timeout = defaultTimeout
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.points[name]; ok {
+		return nil, errors.Wrapf(ErrDuplicatePoint, "%q", name)
+	}
+
+	r.points[name] = o.info
+
+	return &Point[In, Out]{
+		registry: r,
+		info:     o.info,
+		codec:    o.codec,
+		def:      def,
+	}, nil
+}
+
+// Info returns the description of the point.
+func (p *Point[In, Out]) Info() Info {
+	return p.info
+}
+
+// Resolve reports whether the tenant has a plugin for the point.
+func (p *Point[In, Out]) Resolve(ctx context.Context, tenant string) (bool, error) {
+	found, err := p.registry.hasPlugin(ctx, tenant, pluginKey{namespace: p.info.Namespace, plugin: p.info.Plugin})
+	if err != nil {
+		return false, errors.Wrapf(err, "extension.Resolve %s", p.info.Name)
+	}
+
+	return found, nil
+}
+
+// Run runs the point for the tenant: the tenant's plugin if it has one, otherwise the default. The whole run, including
+// looking up the plugins of the tenant, is limited by the timeout of the point.
+func (p *Point[In, Out]) Run(ctx context.Context, tenant string, in In) (Out, error) {
+	var zero Out
+
+	runCtx, cancel := context.WithTimeout(ctx, p.info.Timeout)
+	defer cancel()
+
+	found, err := p.registry.hasPlugin(runCtx, tenant, pluginKey{namespace: p.info.Namespace, plugin: p.info.Plugin})
+	if err != nil {
+		return zero, errors.Wrapf(err, "extension.Run %s", p.info.Name)
+	}
+
+	if !found {
+		out, err := p.def(runCtx, in)
+		if err != nil {
+			return zero, errors.Wrapf(err, "extension.Run %s: default", p.info.Name)
+		}
+
+		return out, nil
+	}
+
+	out, err := se2.ExecCodec[In, Out](runCtx, p.registry.client, p.codec, in, tenant, p.info.Namespace, p.info.Plugin)
+	if err != nil {
+		return zero, errors.Wrapf(err, "extension.Run %s: se2.ExecCodec", p.info.Name)
+	}
+
+	return out, nil
+}
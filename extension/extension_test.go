@@ -0,0 +1,237 @@
+package extension_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+	"github.com/suborbital/se2-go/extension"
+)
+
+const testAccessKey = "eyJrZXkiOjQwNywic2VjcmV0IjoiZWsvNFV3VTBnZ2VHUjdQanF1MmlyaWJacGR1MXZvcWNhMXl3eDE3aWhpTT0ifQ=="
+
+type order struct {
+	Total int `json:"total"`
+}
+
+type quote struct {
+	Total int    `json:"total"`
+	By    string `json:"by"`
+}
+
+func defaultDiscount(_ context.Context, o order) (quote, error) {
+	return quote{Total: o.Total, By: "default"}, nil
+}
+
+// tenantServer lists the discount plugin for the tenant "custom" only, and runs it with a 10% discount. The plugin of
+// the tenant "slow" takes a second.
+func tenantServer(t *testing.T, lists *int32) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/environment/v1/tenant/custom/plugins", "/environment/v1/tenant/slow/plugins":
+			atomic.AddInt32(lists, 1)
+			_, _ = w.Write([]byte(`{"plugins":[{"name":"discount","namespace":"pricing","lang":"js","ref":"abc","apiVersion":"","fqmn":"","uri":""}]}`))
+		case "/environment/v1/tenant/plain/plugins":
+			atomic.AddInt32(lists, 1)
+			_, _ = w.Write([]byte(`{"plugins":[]}`))
+		case "/name/custom/pricing/discount":
+			var o order
+
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&o))
+			assert.NoError(t, json.NewEncoder(w).Encode(quote{Total: o.Total * 9 / 10, By: "plugin"}))
+		case "/name/slow/pricing/discount":
+			_, _ = io.Copy(io.Discard, r.Body)
+
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestPoint_Run(t *testing.T) {
+	var lists int32
+
+	srv := tenantServer(t, &lists)
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	registry := extension.NewRegistry(client)
+
+	discount, err := extension.Declare(registry, "discount", defaultDiscount,
+		extension.Namespace("pricing"),
+		extension.Timeout(50*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		tenant  string
+		want    quote
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{tenant: "custom", want: quote{Total: 90, By: "plugin"}, wantErr: assert.NoError},
+		{tenant: "plain", want: quote{Total: 100, By: "default"}, wantErr: assert.NoError},
+		{tenant: "slow", wantErr: assert.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tenant, func(t *testing.T) {
+			got, err := discount.Run(context.Background(), tt.tenant, order{Total: 100})
+			tt.wantErr(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err = discount.Run(context.Background(), "custom", order{Total: 100})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&lists), "the plugins of a tenant are remembered")
+
+	registry.Forget("custom")
+
+	found, err := discount.Resolve(context.Background(), "custom")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&lists))
+}
+
+func TestDeclare(t *testing.T) {
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts("http://localhost", "http://localhost"))
+	require.NoError(t, err)
+
+	registry := extension.NewRegistry(client)
+
+	_, err = extension.Declare(registry, "discount", defaultDiscount)
+	require.NoError(t, err)
+
+	_, err = extension.Declare(registry, "discount", defaultDiscount)
+	assert.ErrorIs(t, err, extension.ErrDuplicatePoint)
+
+	_, err = extension.Declare(registry, "greeting", func(_ context.Context, name string) (string, error) {
+		return "hello " + name, nil
+	}, extension.Plugin("greet"), extension.Timeout(time.Second))
+	require.NoError(t, err)
+
+	// A timeout that is not positive falls back to the default, instead of failing every run.
+	total, err := extension.Declare(registry, "total", defaultDiscount, extension.Timeout(0))
+	require.NoError(t, err)
+
+	assert.Equal(t, []extension.Info{
+		{Name: "discount", Namespace: "default", Plugin: "discount", Timeout: 5 * time.Second},
+		{Name: "greeting", Namespace: "default", Plugin: "greet", Timeout: time.Second},
+		{Name: "total", Namespace: "default", Plugin: "total", Timeout: 5 * time.Second},
+	}, registry.Points())
+	assert.Equal(t, 5*time.Second, total.Info().Timeout)
+}
+
+func TestPoint_Run_UnknownTenant(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/environment/v1/tenant/new/plugins", r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	discount, err := extension.Declare(extension.NewRegistry(client), "discount", defaultDiscount, extension.Namespace("pricing"))
+	require.NoError(t, err)
+
+	got, err := discount.Run(context.Background(), "new", order{Total: 100})
+	require.NoError(t, err, "a tenant that does not exist yet has no plugins")
+	assert.Equal(t, quote{Total: 100, By: "default"}, got)
+}
+
+func TestPoint_Run_FailingRefresh(t *testing.T) {
+	var (
+		lists   int32
+		failing atomic.Bool
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/environment/v1/tenant/custom/plugins", "/environment/v1/tenant/other/plugins":
+			atomic.AddInt32(&lists, 1)
+
+			if failing.Load() {
+				time.Sleep(50 * time.Millisecond)
+				w.WriteHeader(http.StatusServiceUnavailable)
+
+				return
+			}
+
+			_, _ = w.Write([]byte(`{"plugins":[]}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	registry := extension.NewRegistry(client, extension.WithRefreshInterval(10*time.Millisecond))
+
+	discount, err := extension.Declare(registry, "discount", defaultDiscount, extension.Namespace("pricing"))
+	require.NoError(t, err)
+
+	_, err = discount.Run(context.Background(), "custom", order{Total: 100})
+	require.NoError(t, err)
+
+	failing.Store(true)
+	time.Sleep(20 * time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+
+		got, err := discount.Run(context.Background(), "custom", order{Total: 100})
+		require.NoError(t, err, "the plugins from before are used while the refresh fails")
+		assert.Equal(t, quote{Total: 100, By: "default"}, got)
+		assert.Less(t, time.Since(start), 40*time.Millisecond, "the refresh does not hold up the run")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&lists), "a failed refresh is not retried right away")
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := discount.Run(context.Background(), "other", order{Total: 100})
+			assert.ErrorIs(t, err, se2.ErrServerError)
+		}()
+	}
+
+	wg.Wait()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&lists), "concurrent lookups of a tenant share one call")
+
+	start := time.Now()
+
+	_, err = discount.Run(context.Background(), "other", order{Total: 100})
+	assert.ErrorIs(t, err, se2.ErrServerError)
+	assert.Less(t, time.Since(start), 40*time.Millisecond, "the last error is returned until the backoff is over")
+	assert.Equal(t, int32(3), atomic.LoadInt32(&lists))
+}
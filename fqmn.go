@@ -0,0 +1,129 @@
+package se2
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/suborbital/systemspec/fqmn"
+)
+
+const fqmnPrefix = "fqmn://"
+
+// ErrInvalidFQMN is returned when an FQMN can not be parsed, or one of its segments is not valid.
+var ErrInvalidFQMN = errors.New("invalid FQMN")
+
+// fqmnSegment matches the characters of a valid tenant, namespace part, plugin, or ref in an FQMN, see validSegment.
+var fqmnSegment = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// validSegment reports whether s is a valid tenant, namespace part, plugin, or ref. The dot segments "." and ".." are
+// not, as they would change which path of the edge a request is sent to.
+func validSegment(s string) bool {
+	return s != "." && s != ".." && fqmnSegment.MatchString(s)
+}
+
+// FQMN is a fully-qualified module name, which identifies a plugin of a tenant, and optionally a version of it by its
+// ref. Its text form is fqmn://<tenant>/<namespace>/<plugin>@<ref>, and the namespace can have multiple levels, like
+// api/users. The FQMN field of a Plugin returned by GetPlugins is in this form.
+type FQMN struct {
+	Tenant    string
+	Namespace string
+	Plugin    string
+	Ref       string
+}
+
+// ParseFQMN parses and validates an FQMN in its text form. The ref is optional.
+func ParseFQMN(s string) (FQMN, error) {
+	if !strings.HasPrefix(s, fqmnPrefix) {
+		return FQMN{}, errors.Wrapf(ErrInvalidFQMN, "%q must start with %s", s, fqmnPrefix)
+	}
+
+	parsed, err := fqmn.Parse(s)
+	if err != nil {
+		return FQMN{}, errors.Wrapf(ErrInvalidFQMN, "%q: %s", s, err.Error())
+	}
+
+	f := FQMN{
+		Tenant:    parsed.Tenant,
+		Namespace: parsed.Namespace,
+		Plugin:    parsed.Name,
+		Ref:       parsed.Ref,
+	}
+
+	err = f.Validate()
+	if err != nil {
+		return FQMN{}, err
+	}
+
+	return f, nil
+}
+
+// Validate checks that the tenant, every level of the namespace, and the plugin are present, and that they, and the
+// ref if there is one, only contain letters, digits, dots, dashes, and underscores, and are not "." or "..".
+func (f FQMN) Validate() error {
+	type segment struct {
+		name  string
+		value string
+	}
+
+	segments := []segment{{"tenant", f.Tenant}}
+
+	for _, part := range strings.Split(f.Namespace, "/") {
+		segments = append(segments, segment{"namespace", part})
+	}
+
+	segments = append(segments, segment{"plugin", f.Plugin})
+
+	if f.Ref != emptyString {
+		segments = append(segments, segment{"ref", f.Ref})
+	}
+
+	for _, s := range segments {
+		if !validSegment(s.value) {
+			return errors.Wrapf(ErrInvalidFQMN, "%s %q is not valid", s.name, s.value)
+		}
+	}
+
+	return nil
+}
+
+// String returns the text form of the FQMN. The ref is left out if it's empty.
+func (f FQMN) String() string {
+	s := fqmnPrefix + f.Tenant + "/" + f.Namespace + "/" + f.Plugin
+	if f.Ref != emptyString {
+		s += "@" + f.Ref
+	}
+
+	return s
+}
+
+// Target returns the ident, namespace, and plugin triad of the FQMN.
+func (f FQMN) Target() ExecTarget {
+	return ExecTarget{
+		Ident:     f.Tenant,
+		Namespace: f.Namespace,
+		Plugin:    f.Plugin,
+	}
+}
+
+// ParseFQMN parses the FQMN field of the plugin.
+func (p Plugin) ParseFQMN() (FQMN, error) {
+	return ParseFQMN(p.FQMN)
+}
+
+// ExecFQMN runs the plugin identified by the FQMN with the payload as input. If the FQMN has a ref, like the ones
+// returned by GetPlugins do, that exact version is run with ExecRef. Otherwise the live version is run, like Exec does;
+// clear the Ref field to run the live version of a plugin whose FQMN has a ref.
+func (c *Client) ExecFQMN(ctx context.Context, f FQMN, payload []byte, opts ...ExecOption) ([]byte, error) {
+	err := f.Validate()
+	if err != nil {
+		return nil, errors.Wrap(err, "client.ExecFQMN")
+	}
+
+	if f.Ref != emptyString {
+		return c.ExecRef(ctx, payload, f.Ref, opts...)
+	}
+
+	return c.Exec(ctx, payload, f.Tenant, f.Namespace, f.Plugin, opts...)
+}
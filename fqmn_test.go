@@ -0,0 +1,119 @@
+package se2_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+)
+
+func TestParseFQMN(t *testing.T) {
+	tests := []struct {
+		name    string
+		fqmn    string
+		want    se2.FQMN
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "with ref",
+			fqmn:    "fqmn://acme/default/hello@98qhrfgo3089",
+			want:    se2.FQMN{Tenant: "acme", Namespace: "default", Plugin: "hello", Ref: "98qhrfgo3089"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "nested namespace without ref",
+			fqmn:    "fqmn://suborbital.acmeco/api/users/add-user",
+			want:    se2.FQMN{Tenant: "suborbital.acmeco", Namespace: "api/users", Plugin: "add-user"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "name uri form",
+			fqmn:    "/name/default/hello",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "missing plugin",
+			fqmn:    "fqmn://acme/default",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "empty namespace level",
+			fqmn:    "fqmn://acme/api//hello",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "dot dot namespace level",
+			fqmn:    "fqmn://acme/api/../victim/hello",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "query in plugin",
+			fqmn:    "fqmn://acme/default/hello?x=1",
+			wantErr: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := se2.ParseFQMN(tt.fqmn)
+			tt.wantErr(t, err)
+
+			if err != nil {
+				assert.ErrorIs(t, err, se2.ErrInvalidFQMN)
+
+				return
+			}
+
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.fqmn, got.String())
+		})
+	}
+}
+
+func TestClient_ExecFQMN(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/environment/v1/tenant/acme/plugins":
+			_, _ = w.Write([]byte(`{"plugins":[{"name":"hello","namespace":"api/v1","lang":"js","ref":"abc123","apiVersion":"","fqmn":"fqmn://acme/api/v1/hello@abc123","uri":""}]}`))
+		case "/ref/abc123":
+			_, _ = w.Write([]byte(`pinned`))
+		case "/name/acme/api/v1/hello":
+			_, _ = w.Write([]byte(`live`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	plugins, err := client.GetPlugins(context.Background(), "acme")
+	require.NoError(t, err)
+	require.Len(t, plugins.Plugins, 1)
+
+	f, err := plugins.Plugins[0].ParseFQMN()
+	require.NoError(t, err)
+
+	// The FQMN from GetPlugins has a ref, so that version runs.
+	out, err := client.ExecFQMN(context.Background(), f, []byte(`hello`))
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`pinned`), out)
+
+	f.Ref = ""
+
+	out, err = client.ExecFQMN(context.Background(), f, []byte(`hello`))
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`live`), out)
+
+	_, err = client.ExecFQMN(context.Background(), se2.FQMN{Tenant: "acme", Namespace: "default", Plugin: "../tenant"}, nil)
+	assert.ErrorIs(t, err, se2.ErrInvalidFQMN)
+
+	_, err = client.ExecFQMN(context.Background(), se2.FQMN{Tenant: "acme", Namespace: "..", Plugin: "hello"}, nil)
+	assert.ErrorIs(t, err, se2.ErrInvalidFQMN)
+}
@@ -0,0 +1,400 @@
+// Package handler exposes the plugins of tenants as HTTP endpoints of a host application.
+//
+// The Handler resolves the tenant of every request with a TenantResolver, maps what's left of the path to a namespace
+// and plugin with a Router, and streams the request body to the plugin with ExecStream. The output of the plugin is
+// streamed back with its headers:
+//
+//	h := handler.New(client, handler.SubdomainTenant("plugins.example.com"),
+//		handler.WithAuthorizer(checkSession),
+//	)
+//
+//	http.Handle("/", h)
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/se2-go"
+)
+
+const (
+	defaultNamespace = "default"
+
+	// headerRequestID is set by the edge on a request before it runs the plugin, and sent back with the response.
+	headerRequestID = "X-Suborbital-RequestID"
+)
+
+var (
+	// ErrNoTenant is returned by a TenantResolver when the request does not name a tenant.
+	ErrNoTenant = errors.New("request does not name a tenant")
+
+	// ErrNoRoute is returned by a Router when the path does not map to a plugin.
+	ErrNoRoute = errors.New("path does not map to a plugin")
+)
+
+// hopHeaders are the headers that describe a single connection, which are not copied from the plugin response. The
+// length of the streamed output is not known up front, so Content-Length is left out as well.
+var hopHeaders = []string{
+	"Connection",
+	"Content-Length",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// TenantResolver finds the tenant a request is for. It returns the tenant, and the path that is left to route, without
+// the part that named the tenant. HeaderTenant, SubdomainTenant, and PathTenant read the tenant from the request as the
+// caller sent it, so they do not prove the caller belongs to the tenant; that's up to an Authorizer.
+type TenantResolver func(r *http.Request) (tenant, path string, err error)
+
+// HeaderTenant resolves the tenant from the value of a request header, like X-Tenant. Whoever sends the request picks the
+// tenant, so only use it behind a proxy that sets the header itself, or with an Authorizer that checks the caller is
+// allowed to act for the tenant. Otherwise anyone can run the plugins of any tenant.
+func HeaderTenant(name string) TenantResolver {
+	return func(r *http.Request) (string, string, error) {
+		tenant := strings.TrimSpace(r.Header.Get(name))
+		if tenant == "" {
+			return "", "", errors.Wrapf(ErrNoTenant, "header %s is empty", name)
+		}
+
+		return tenant, r.URL.Path, nil
+	}
+}
+
+// SubdomainTenant resolves the tenant from the subdomain of the host the request was sent to. With the domain
+// plugins.example.com, a request to acme.plugins.example.com is for the tenant acme.
+func SubdomainTenant(domain string) TenantResolver {
+	suffix := "." + strings.ToLower(strings.Trim(domain, "."))
+
+	return func(r *http.Request) (string, string, error) {
+		host := strings.ToLower(r.Host)
+		if i := strings.LastIndexByte(host, ':'); i > strings.LastIndexByte(host, ']') {
+			host = host[:i]
+		}
+
+		tenant := strings.TrimSuffix(host, suffix)
+		if tenant == host || tenant == "" || strings.Contains(tenant, ".") {
+			return "", "", errors.Wrapf(ErrNoTenant, "host %s is not a subdomain of %s", r.Host, suffix[1:])
+		}
+
+		return tenant, r.URL.Path, nil
+	}
+}
+
+// PathTenant resolves the tenant from the first segment of the path, so /acme/orders/total is for the tenant acme, and
+// /orders/total is left to route.
+func PathTenant() TenantResolver {
+	return func(r *http.Request) (string, string, error) {
+		tenant, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if tenant == "" {
+			return "", "", errors.Wrap(ErrNoTenant, "path is empty")
+		}
+
+		return tenant, "/" + path, nil
+	}
+}
+
+// ContextTenant resolves the tenant from the context of the request, for services whose own middleware already found
+// it and stored it with se2.WithTenant.
+func ContextTenant() TenantResolver {
+	return func(r *http.Request) (string, string, error) {
+		tenant, ok := se2.TenantFromContext(r.Context())
+		if !ok {
+			return "", "", errors.Wrap(ErrNoTenant, "context does not carry a tenant")
+		}
+
+		return tenant, r.URL.Path, nil
+	}
+}
+
+// Router maps the path of a request, after the tenant was resolved, to the namespace and plugin that serve it.
+type Router func(r *http.Request, path string) (namespace, plugin string, err error)
+
+// DefaultRouter maps /plugin to the plugin in the default namespace, and /namespace/plugin to the plugin in the
+// namespace. Namespaces can have multiple levels, so /api/users/add maps to the plugin add in api/users.
+func DefaultRouter(_ *http.Request, path string) (string, string, error) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "", "", errors.Wrap(ErrNoRoute, "path is empty")
+	}
+
+	i := strings.LastIndexByte(path, '/')
+	if i < 0 {
+		return defaultNamespace, path, nil
+	}
+
+	return path[:i], path[i+1:], nil
+}
+
+// Route is the namespace and plugin a path maps to.
+type Route struct {
+	Namespace string
+	Plugin    string
+}
+
+// Routes returns a Router with a fixed set of paths, like "/orders/total". Paths that are not in the map return
+// ErrNoRoute.
+func Routes(routes map[string]Route) Router {
+	return func(_ *http.Request, path string) (string, string, error) {
+		route, ok := routes[path]
+		if !ok {
+			return "", "", errors.Wrapf(ErrNoRoute, "%s", path)
+		}
+
+		return route.Namespace, route.Plugin, nil
+	}
+}
+
+// Authorizer decides whether the request can run the plugin of the tenant. If it returns an error, the plugin is not
+// run, and the error is rendered as an *AuthError.
+type Authorizer func(r *http.Request, tenant, namespace, plugin string) error
+
+// AuthError is the error of an Authorizer that refused a request.
+type AuthError struct {
+	Err error
+}
+
+// Error implements the error interface.
+func (e *AuthError) Error() string {
+	return "request is not authorized: " + e.Err.Error()
+}
+
+// Unwrap returns the error of the authorizer.
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// PluginError is the error of a plugin that ran and failed, as opposed to a failure of SE2 itself, like a revoked
+// access key or a rate limit. The edge gives a request an X-Suborbital-RequestID header once it's about to run the
+// plugin, and sends it back with the response, so failures from before that point do not have it.
+type PluginError struct {
+	// Err holds the status code and body the plugin failed with, and Header the headers of its response.
+	Err    *se2.APIError
+	Header http.Header
+}
+
+// Error implements the error interface.
+func (e *PluginError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the API error.
+func (e *PluginError) Unwrap() error {
+	return e.Err
+}
+
+// pluginError returns the error of the execution as a *PluginError if the response came from the plugin, and unchanged
+// otherwise.
+func pluginError(err error, header http.Header) error {
+	var apiErr *se2.APIError
+	if !errors.As(err, &apiErr) || header.Get(headerRequestID) == "" {
+		return err
+	}
+
+	return &PluginError{Err: apiErr, Header: header}
+}
+
+// ErrorRenderer writes the response for an error. The error is one of the resolver, the router, the authorizer, or the
+// execution, which is a *PluginError when the plugin failed. Tenants, namespaces, and plugins whose names are not valid
+// are refused with an error that matches se2.ErrInvalidFQMN.
+type ErrorRenderer func(w http.ResponseWriter, r *http.Request, err error)
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithRouter sets how the path of a request is mapped to a plugin. Defaults to DefaultRouter.
+func WithRouter(router Router) Option {
+	return func(h *Handler) {
+		h.route = router
+	}
+}
+
+// WithAuthorizer sets a hook that is called before a plugin is run. By default, every request is allowed.
+func WithAuthorizer(authorizer Authorizer) Option {
+	return func(h *Handler) {
+		h.authorize = authorizer
+	}
+}
+
+// WithErrorRenderer sets how errors are written to the response. Defaults to DefaultErrorRenderer.
+func WithErrorRenderer(renderer ErrorRenderer) Option {
+	return func(h *Handler) {
+		h.renderError = renderer
+	}
+}
+
+// WithForwardedHeaders sets the request headers that are passed on to the plugin. Content-Type is always passed on,
+// and so is the query string.
+func WithForwardedHeaders(names ...string) Option {
+	return func(h *Handler) {
+		h.forward = append(h.forward, names...)
+	}
+}
+
+// Handler is an http.Handler that runs the plugin of a tenant for every request, see New.
+type Handler struct {
+	client *se2.Client
+
+	resolve     TenantResolver
+	route       Router
+	authorize   Authorizer
+	renderError ErrorRenderer
+	forward     []string
+}
+
+// New returns a Handler that runs plugins with the client, for the tenant the resolver finds for every request. The
+// resolver decides whose plugins a request can run, so unless it only trusts what the host application itself set,
+// like ContextTenant after an authenticating middleware, also set an Authorizer.
+func New(client *se2.Client, resolver TenantResolver, opts ...Option) *Handler {
+	h := &Handler{
+		client:      client,
+		resolve:     resolver,
+		route:       DefaultRouter,
+		renderError: DefaultErrorRenderer,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// ServeHTTP resolves the tenant and the plugin of the request, and streams the request body through the plugin. The
+// context of the request is given the tenant with se2.WithTenant, so the router, the authorizer, and the error renderer
+// can read it with se2.TenantFromContext.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenant, path, err := h.resolve(r)
+	if err != nil {
+		h.renderError(w, r, err)
+
+		return
+	}
+
+	h.serve(w, r.WithContext(se2.WithTenant(r.Context(), tenant)), tenant, path)
+}
+
+// serve routes the request of the tenant to a plugin, and streams the request body through it.
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request, tenant, path string) {
+	namespace, plugin, err := h.route(r, path)
+	if err != nil {
+		h.renderError(w, r, err)
+
+		return
+	}
+
+	// The names come from the request, and end up in the path of the edge, so one like ".." could reach the plugins of
+	// another tenant.
+	err = se2.FQMN{Tenant: tenant, Namespace: namespace, Plugin: plugin}.Validate()
+	if err != nil {
+		h.renderError(w, r, err)
+
+		return
+	}
+
+	if h.authorize != nil {
+		err := h.authorize(r, tenant, namespace, plugin)
+		if err != nil {
+			h.renderError(w, r, &AuthError{Err: err})
+
+			return
+		}
+	}
+
+	header := make(http.Header)
+	opts := []se2.ExecOption{se2.ExecResponseHeader(header)}
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		opts = append(opts, se2.ExecContentType(contentType))
+	}
+
+	for _, name := range h.forward {
+		for _, value := range r.Header.Values(name) {
+			opts = append(opts, se2.ExecHeader(name, value))
+		}
+	}
+
+	for key, values := range r.URL.Query() {
+		for _, value := range values {
+			opts = append(opts, se2.ExecQuery(key, value))
+		}
+	}
+
+	out, err := h.client.ExecStream(r.Context(), r.Body, tenant, namespace, plugin, opts...)
+	if err != nil {
+		h.renderError(w, r, pluginError(err, header))
+
+		return
+	}
+
+	defer func() {
+		_ = out.Close()
+	}()
+
+	copyHeader(w.Header(), header)
+	w.WriteHeader(http.StatusOK)
+
+	// The client may have gone away, there is nobody left to tell about it.
+	_, _ = io.Copy(w, out)
+}
+
+// copyHeader copies the headers of the plugin response to the response, leaving out the hop-by-hop ones.
+func copyHeader(dst, src http.Header) {
+	for key, values := range src {
+		dst[key] = append(dst[key], values...)
+	}
+
+	for _, key := range hopHeaders {
+		dst.Del(key)
+	}
+}
+
+// DefaultErrorRenderer writes the status code, headers, and body the plugin failed with if the error is a
+// *PluginError. Otherwise it writes a plain text error with a status code that fits the error: 400 if there is no
+// tenant or a name is not valid, 404 if there is no route, 403 if the authorizer refused the request, 413 if a payload
+// was too large, 504 if it timed out, and 502 otherwise. Only the failures of the plugin show their details, so the
+// internals of the host application and the responses of SE2 itself do not leak. The body of a plugin failure is cut
+// off at 64 KiB by the client, so a longer one is rendered as a 502 too, rather than passed on incomplete.
+func DefaultErrorRenderer(w http.ResponseWriter, r *http.Request, err error) {
+	var pluginErr *PluginError
+	if errors.As(err, &pluginErr) && !pluginErr.Err.Truncated {
+		copyHeader(w.Header(), pluginErr.Header)
+
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+
+		w.WriteHeader(pluginErr.Err.StatusCode)
+		_, _ = w.Write(pluginErr.Err.Body)
+
+		return
+	}
+
+	status := http.StatusBadGateway
+
+	var authErr *AuthError
+
+	switch {
+	case errors.Is(err, ErrNoTenant), errors.Is(err, se2.ErrInvalidFQMN):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrNoRoute):
+		status = http.StatusNotFound
+	case errors.Is(err, se2.ErrPayloadTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	case errors.As(err, &authErr):
+		status = http.StatusForbidden
+	}
+
+	http.Error(w, http.StatusText(status), status)
+}
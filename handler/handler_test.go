@@ -0,0 +1,350 @@
+package handler_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+	"github.com/suborbital/se2-go/handler"
+)
+
+const testAccessKey = "eyJrZXkiOjQwNywic2VjcmV0IjoiZWsvNFV3VTBnZ2VHUjdQanF1MmlyaWJacGR1MXZvcWNhMXl3eDE3aWhpTT0ifQ=="
+
+// edgeServer upper cases the request body for /name/acme/default/upper, and echoes back the path, the query, the
+// content type, and the X-Locale header for every other plugin. The plugins "broken" and "verbose" fail, the latter
+// with a body over 64 KiB. Requests for the plugins "denied" and "overloaded" are refused by the edge without running
+// them, so like the real edge, their responses do not have the X-Suborbital-RequestID header.
+func edgeServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/denied"):
+			w.Header().Set("X-Edge-Region", "eu")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`access key revoked`))
+
+			return
+		case strings.HasSuffix(r.URL.Path, "/overloaded"):
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`edge is overloaded`))
+
+			return
+		}
+
+		w.Header().Set("X-Suborbital-RequestID", "req-1")
+
+		switch {
+		case r.URL.Path == "/name/acme/default/upper":
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte(strings.ToUpper(string(b))))
+		case strings.HasSuffix(r.URL.Path, "/broken"):
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`plugin is warming up`))
+		case strings.HasSuffix(r.URL.Path, "/verbose"):
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(strings.Repeat("x", 64<<10+1)))
+		default:
+			_, _ = w.Write([]byte(strings.Join([]string{
+				r.URL.Path,
+				r.URL.RawQuery,
+				r.Header.Get("Content-Type"),
+				r.Header.Get("X-Locale"),
+			}, " ")))
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestHandler(t *testing.T) {
+	srv := edgeServer(t)
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		resolver   handler.TenantResolver
+		opts       []handler.Option
+		host       string
+		path       string
+		header     http.Header
+		wantStatus int
+		wantBody   string
+		wantHeader http.Header
+	}{
+		{
+			name:       "header tenant",
+			path:       "/upper",
+			header:     http.Header{"X-Tenant": {"acme"}},
+			wantStatus: http.StatusOK,
+			wantBody:   "HELLO",
+			wantHeader: http.Header{"X-Suborbital-Requestid": {"req-1"}, "Content-Type": {"text/plain"}},
+		},
+		{
+			name:       "subdomain tenant",
+			resolver:   handler.SubdomainTenant("plugins.example.com"),
+			host:       "acme.plugins.example.com:8080",
+			path:       "/upper",
+			wantStatus: http.StatusOK,
+			wantBody:   "HELLO",
+		},
+		{
+			name:       "path tenant with namespace and forwarded headers",
+			resolver:   handler.PathTenant(),
+			opts:       []handler.Option{handler.WithForwardedHeaders("X-Locale")},
+			path:       "/globex/api/users/add?dry=1",
+			header:     http.Header{"Content-Type": {"application/json"}, "X-Locale": {"nl"}, "X-Secret": {"no"}},
+			wantStatus: http.StatusOK,
+			wantBody:   "/name/globex/api/users/add dry=1 application/json nl",
+		},
+		{
+			name: "routes",
+			opts: []handler.Option{handler.WithRouter(handler.Routes(map[string]handler.Route{
+				"/shout": {Namespace: "default", Plugin: "upper"},
+			}))},
+			path:       "/shout",
+			header:     http.Header{"X-Tenant": {"acme"}},
+			wantStatus: http.StatusOK,
+			wantBody:   "HELLO",
+		},
+		{
+			name:       "no tenant",
+			path:       "/upper",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Bad Request\n",
+		},
+		{
+			name:       "not a subdomain",
+			resolver:   handler.SubdomainTenant("plugins.example.com"),
+			host:       "a.b.plugins.example.com",
+			path:       "/upper",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Bad Request\n",
+		},
+		{
+			name:       "no route",
+			opts:       []handler.Option{handler.WithRouter(handler.Routes(nil))},
+			path:       "/upper",
+			header:     http.Header{"X-Tenant": {"acme"}},
+			wantStatus: http.StatusNotFound,
+			wantBody:   "Not Found\n",
+		},
+		{
+			name: "refused",
+			opts: []handler.Option{handler.WithAuthorizer(func(r *http.Request, tenant, namespace, plugin string) error {
+				return errors.New("no session")
+			})},
+			path:       "/upper",
+			header:     http.Header{"X-Tenant": {"acme"}},
+			wantStatus: http.StatusForbidden,
+			wantBody:   "Forbidden\n",
+		},
+		{
+			name:       "plugin failure",
+			path:       "/broken",
+			header:     http.Header{"X-Tenant": {"acme"}},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   "plugin is warming up",
+			wantHeader: http.Header{"Retry-After": {"1"}},
+		},
+		{
+			name:       "se2 failure",
+			path:       "/denied",
+			header:     http.Header{"X-Tenant": {"acme"}},
+			wantStatus: http.StatusBadGateway,
+			wantBody:   "Bad Gateway\n",
+			wantHeader: http.Header{"X-Edge-Region": {""}},
+		},
+		{
+			name:       "se2 server failure",
+			path:       "/overloaded",
+			header:     http.Header{"X-Tenant": {"acme"}},
+			wantStatus: http.StatusBadGateway,
+			wantBody:   "Bad Gateway\n",
+		},
+		{
+			name:       "plugin failure over the body limit",
+			path:       "/verbose",
+			header:     http.Header{"X-Tenant": {"acme"}},
+			wantStatus: http.StatusBadGateway,
+			wantBody:   "Bad Gateway\n",
+		},
+		{
+			name: "custom error renderer",
+			opts: []handler.Option{handler.WithErrorRenderer(func(w http.ResponseWriter, r *http.Request, err error) {
+				w.WriteHeader(http.StatusTeapot)
+				_, _ = w.Write([]byte(err.Error()))
+			})},
+			path:       "/upper",
+			wantStatus: http.StatusTeapot,
+			wantBody:   "header X-Tenant is empty: request does not name a tenant",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader("hello"))
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+
+			resolver := tt.resolver
+			if resolver == nil {
+				resolver = handler.HeaderTenant("X-Tenant")
+			}
+
+			rec := httptest.NewRecorder()
+
+			handler.New(client, resolver, tt.opts...).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+
+			for key := range tt.wantHeader {
+				assert.Equal(t, tt.wantHeader.Get(key), rec.Header().Get(key), key)
+			}
+
+			assert.Empty(t, rec.Header().Get("Content-Length"))
+		})
+	}
+}
+
+func TestHandler_PayloadTooLarge(t *testing.T) {
+	// The client stops sending the body once it's over the limit, so the server sees it cut off.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL), se2.WithMaxRequestBytes(2))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/upper", strings.NewReader("hello"))
+	req.Header.Set("X-Tenant", "acme")
+
+	rec := httptest.NewRecorder()
+
+	handler.New(client, handler.HeaderTenant("X-Tenant")).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Equal(t, "Request Entity Too Large\n", rec.Body.String())
+}
+
+func TestHandler_Traversal(t *testing.T) {
+	var requests int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	authorized := false
+
+	authorize := func(r *http.Request, tenant, namespace, plugin string) error {
+		authorized = true
+
+		return nil
+	}
+
+	tests := []struct {
+		name     string
+		resolver handler.TenantResolver
+		path     string
+		header   http.Header
+	}{
+		{
+			name:     "encoded slashes in the namespace",
+			resolver: handler.PathTenant(),
+			path:     "/attacker/x%2F..%2F..%2Fvictim%2Fns/plugin",
+		},
+		{
+			name:     "dot dot plugin",
+			resolver: handler.PathTenant(),
+			path:     "/attacker/default/%2E%2E",
+		},
+		{
+			name:     "empty namespace level",
+			resolver: handler.PathTenant(),
+			path:     "/attacker/api//plugin",
+		},
+		{
+			name:     "dot dot tenant",
+			resolver: handler.HeaderTenant("X-Tenant"),
+			path:     "/default/plugin",
+			header:   http.Header{"X-Tenant": {".."}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader("hello"))
+
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+
+			rec := httptest.NewRecorder()
+
+			handler.New(client, tt.resolver, handler.WithAuthorizer(authorize)).ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+
+	assert.False(t, authorized, "invalid names are refused before the authorizer is called")
+	assert.Zero(t, requests)
+}
+
+func TestHandler_TenantInContext(t *testing.T) {
+	srv := edgeServer(t)
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	var authorized string
+
+	h := handler.New(client, handler.ContextTenant(),
+		handler.WithAuthorizer(func(r *http.Request, tenant, namespace, plugin string) error {
+			authorized, _ = se2.TenantFromContext(r.Context())
+
+			return nil
+		}),
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/upper", strings.NewReader("hello"))
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req.WithContext(se2.WithTenant(req.Context(), "acme")))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "HELLO", rec.Body.String())
+	assert.Equal(t, "acme", authorized)
+
+	rec = httptest.NewRecorder()
+
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/upper", strings.NewReader("hello")))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
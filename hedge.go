@@ -0,0 +1,242 @@
+package se2
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultHedgeDelay      = 100 * time.Millisecond
+	defaultHedgeMinSamples = 20
+	hedgeWindowSize        = 200
+)
+
+// HedgePolicy configures hedged executions, see WithHedging.
+type HedgePolicy struct {
+	// Plugins are the plugins whose executions are hedged. A hedged plugin may run twice for a single call, so only list
+	// plugins that are safe to run more than once with the same input.
+	Plugins []ExecTarget
+
+	// Delay is how long to wait for the first request before the hedge is sent. Defaults to 100 milliseconds if it's not
+	// positive, so a hedged plugin is never sent two requests at once.
+	Delay time.Duration
+
+	// Percentile, if between 0 and 1, sends the hedge once the first request took longer than that percentile of the
+	// recent latencies of the plugin, like 0.95 for the p95. Delay is used until MinSamples latencies were measured,
+	// which defaults to 20.
+	Percentile float64
+	MinSamples int
+}
+
+// HedgeStats counts hedged executions.
+type HedgeStats struct {
+	// Calls is how many calls to Exec were for hedged plugins, Hedges how many of those sent a hedge, and HedgeWins how
+	// many were answered by the hedge first.
+	Calls     uint64
+	Hedges    uint64
+	HedgeWins uint64
+}
+
+// WithHedging turns on hedged executions for the plugins of the policy, to cut the tail latency of Exec. If the first
+// request has not returned after the delay of the policy, a second identical request is sent, and the output of
+// whichever succeeds first is returned. The other one is cancelled.
+//
+// A request that fails before the hedge is sent is not hedged; use WithRetryPolicy to retry failures.
+func WithHedging(policy HedgePolicy) ClientOption {
+	return func(c *Client) {
+		c.hedger = newHedger(policy)
+	}
+}
+
+// HedgeStats returns how often executions were hedged. It's all zeroes if hedging is not turned on.
+func (c *Client) HedgeStats() HedgeStats {
+	if c.hedger == nil {
+		return HedgeStats{}
+	}
+
+	return HedgeStats{
+		Calls:     c.hedger.calls.Load(),
+		Hedges:    c.hedger.hedges.Load(),
+		HedgeWins: c.hedger.wins.Load(),
+	}
+}
+
+// hedger keeps the recent latencies of the hedged plugins, and the counters.
+type hedger struct {
+	policy  HedgePolicy
+	plugins map[ExecTarget]*latencyWindow
+
+	calls  atomic.Uint64
+	hedges atomic.Uint64
+	wins   atomic.Uint64
+}
+
+// newHedger creates a hedger for the policy, filling in the defaults.
+func newHedger(policy HedgePolicy) *hedger {
+	if policy.Delay <= 0 {
+		policy.Delay = defaultHedgeDelay
+	}
+
+	if policy.MinSamples <= 0 {
+		policy.MinSamples = defaultHedgeMinSamples
+	}
+
+	h := &hedger{
+		policy:  policy,
+		plugins: make(map[ExecTarget]*latencyWindow, len(policy.Plugins)),
+	}
+
+	for _, target := range policy.Plugins {
+		h.plugins[target] = &latencyWindow{}
+	}
+
+	return h
+}
+
+// window returns the latencies of the plugin, or false if it's not hedged. It's safe to call on a nil hedger.
+func (h *hedger) window(target ExecTarget) (*latencyWindow, bool) {
+	if h == nil {
+		return nil, false
+	}
+
+	w, ok := h.plugins[target]
+
+	return w, ok
+}
+
+// delay returns how long to wait before sending the hedge for the plugin.
+func (h *hedger) delay(w *latencyWindow) time.Duration {
+	if h.policy.Percentile <= 0 || h.policy.Percentile >= 1 {
+		return h.policy.Delay
+	}
+
+	d, ok := w.percentile(h.policy.Percentile, h.policy.MinSamples)
+	if !ok {
+		return h.policy.Delay
+	}
+
+	return d
+}
+
+// latencyWindow is a ring buffer of the most recent latencies of a plugin.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples [hedgeWindowSize]time.Duration
+	next    int
+	count   int
+}
+
+// add records a latency.
+func (w *latencyWindow) add(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % hedgeWindowSize
+
+	if w.count < hedgeWindowSize {
+		w.count++
+	}
+}
+
+// percentile returns the p-th percentile of the latencies, or false if there are fewer than minSamples of them.
+func (w *latencyWindow) percentile(p float64, minSamples int) (time.Duration, bool) {
+	w.mu.Lock()
+	sorted := make([]time.Duration, w.count)
+	copy(sorted, w.samples[:w.count])
+	w.mu.Unlock()
+
+	if len(sorted) < minSamples {
+		return 0, false
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+
+	return sorted[i], true
+}
+
+// hedgeResult is the outcome of one of the requests of a hedged execution.
+type hedgeResult struct {
+	output []byte
+	header http.Header
+	err    error
+	hedge  bool
+}
+
+// execHedged runs execOnce, and runs it again if the first one did not return within the hedge delay. It returns the
+// first success, or the last failure if both failed.
+func (c *Client) execHedged(ctx context.Context, w *latencyWindow, payload []byte, target ExecTarget, opts []ExecOption) ([]byte, error) {
+	c.hedger.calls.Add(1)
+
+	hedgeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Buffered so the request that loses does not block after we returned.
+	results := make(chan hedgeResult, 2)
+
+	// Each request captures its response headers on its own, and only the ones of the result we return are passed on.
+	o := newExecOptions(opts)
+
+	run := func(hedge bool) {
+		header := make(http.Header)
+		attemptOpts := append(opts[:len(opts):len(opts)], ExecResponseHeader(header))
+
+		output, err := c.execOnce(hedgeCtx, payload, target, attemptOpts)
+		results <- hedgeResult{output: output, header: header, err: err, hedge: hedge}
+	}
+
+	start := time.Now()
+
+	go run(false)
+
+	timer := time.NewTimer(c.hedger.delay(w))
+	defer timer.Stop()
+
+	hedged := false
+	inFlight := 1
+
+	for {
+		select {
+		case <-timer.C:
+			hedged = true
+			inFlight++
+
+			c.hedger.hedges.Add(1)
+
+			go run(true)
+		case r := <-results:
+			inFlight--
+
+			// If this one failed, but the other one is still running, wait for it.
+			if r.err != nil && hedged && inFlight > 0 {
+				continue
+			}
+
+			o.captureHeader(&http.Response{Header: r.header})
+
+			if r.err != nil {
+				return nil, r.err
+			}
+
+			w.add(time.Since(start))
+
+			if r.hedge {
+				c.hedger.wins.Add(1)
+			}
+
+			return r.output, nil
+		}
+	}
+}
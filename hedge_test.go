@@ -0,0 +1,146 @@
+package se2_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+)
+
+// slowFirstServer answers every request right away, except the ones whose number is in slow, which hang until the
+// client goes away. It counts the requests that were cancelled by the client.
+func slowFirstServer(t *testing.T, slow map[int32]bool, requests, cancelled *int32) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices the client went away once the body was read.
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		n := atomic.AddInt32(requests, 1)
+
+		if slow[n] {
+			<-r.Context().Done()
+			atomic.AddInt32(cancelled, 1)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`fast`))
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestWithHedging_Delay(t *testing.T) {
+	var requests, cancelled int32
+
+	srv := slowFirstServer(t, map[int32]bool{1: true}, &requests, &cancelled)
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL, srv.URL),
+		se2.WithHedging(se2.HedgePolicy{Plugins: []se2.ExecTarget{pure}, Delay: 10 * time.Millisecond}),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	out, err := client.Exec(ctx, []byte(`hi`), "acme", "default", "pure")
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`fast`), out)
+
+	out, err = client.Exec(ctx, []byte(`hi`), "acme", "default", "pure")
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`fast`), out)
+
+	assert.Equal(t, se2.HedgeStats{Calls: 2, Hedges: 1, HedgeWins: 1}, client.HedgeStats())
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&cancelled) == 1
+	}, time.Second, 5*time.Millisecond, "the first request is cancelled")
+
+	_, err = client.Exec(ctx, []byte(`hi`), "acme", "default", "other")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), client.HedgeStats().Calls, "not a hedged plugin")
+}
+
+func TestWithHedging_DefaultDelay(t *testing.T) {
+	var requests int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		atomic.AddInt32(&requests, 1)
+
+		time.Sleep(10 * time.Millisecond)
+
+		_, _ = w.Write([]byte(`slow`))
+	}))
+	defer srv.Close()
+
+	// Without a delay, the hedge is not sent right away with the first request.
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL, srv.URL),
+		se2.WithHedging(se2.HedgePolicy{Plugins: []se2.ExecTarget{pure}}),
+	)
+	require.NoError(t, err)
+
+	_, err = client.Exec(context.Background(), []byte(`hi`), "acme", "default", "pure")
+	require.NoError(t, err)
+
+	assert.Equal(t, se2.HedgeStats{Calls: 1}, client.HedgeStats())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestWithHedging_Percentile(t *testing.T) {
+	var requests, cancelled int32
+
+	srv := slowFirstServer(t, map[int32]bool{6: true}, &requests, &cancelled)
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL, srv.URL),
+		se2.WithHedging(se2.HedgePolicy{
+			Plugins:    []se2.ExecTarget{pure},
+			Delay:      time.Hour,
+			Percentile: 0.9,
+			MinSamples: 5,
+		}),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for i := 0; i < 6; i++ {
+		_, err = client.Exec(ctx, []byte(`hi`), "acme", "default", "pure")
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, se2.HedgeStats{Calls: 6, Hedges: 1, HedgeWins: 1}, client.HedgeStats())
+}
+
+func TestWithHedging_FailureBeforeHedge(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL, srv.URL),
+		se2.WithHedging(se2.HedgePolicy{Plugins: []se2.ExecTarget{pure}, Delay: time.Hour}),
+	)
+	require.NoError(t, err)
+
+	_, err = client.Exec(context.Background(), []byte(`hi`), "acme", "default", "pure")
+	assert.ErrorIs(t, err, se2.ErrBadRequest)
+	assert.Equal(t, se2.HedgeStats{Calls: 1}, client.HedgeStats())
+}
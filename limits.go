@@ -0,0 +1,189 @@
+package se2
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// ErrPayloadTooLarge is matched by the *PayloadTooLargeError returned when a payload is larger than the configured limit
+// allows.
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// PayloadTooLargeError is returned when a request payload or a response is larger than its limit, see
+// WithMaxRequestBytes and WithMaxResponseBytes. It matches ErrPayloadTooLarge.
+type PayloadTooLargeError struct {
+	// Request is true if the request payload was too large, and false if the response was.
+	Request bool
+
+	// Limit is the limit in bytes, and Size the size of the payload, or -1 if it's not known because it was streamed.
+	Limit int64
+	Size  int64
+}
+
+// Error implements the error interface.
+func (e *PayloadTooLargeError) Error() string {
+	what := "response"
+	if e.Request {
+		what = "request payload"
+	}
+
+	if e.Size < 0 {
+		return fmt.Sprintf("%s is larger than the limit of %d bytes", what, e.Limit)
+	}
+
+	return fmt.Sprintf("%s of %d bytes is larger than the limit of %d bytes", what, e.Size, e.Limit)
+}
+
+// Is makes the error match ErrPayloadTooLarge.
+func (e *PayloadTooLargeError) Is(target error) bool {
+	return target == ErrPayloadTooLarge
+}
+
+// WithMaxResponseBytes limits how much of any response the client reads into memory, both the JSON responses of the
+// admin API and the output of plugins that Exec returns. Larger responses fail with a *PayloadTooLargeError instead.
+// Zero, the default, means no limit. ExecStream is not affected, as its caller decides how much to read, unless it's
+// passed ExecMaxResponseBytes.
+func WithMaxResponseBytes(limit int64) ClientOption {
+	return func(c *Client) {
+		c.maxResponseBytes = limit
+	}
+}
+
+// WithMaxExecResponseBytes limits how much of the output of a plugin the buffered exec methods read into memory. It
+// takes precedence over WithMaxResponseBytes for plugin outputs, so the admin API and the plugins can have different
+// limits. Zero, the default, means the limit of WithMaxResponseBytes applies.
+func WithMaxExecResponseBytes(limit int64) ClientOption {
+	return func(c *Client) {
+		c.maxExecResponseBytes = limit
+	}
+}
+
+// WithMaxRequestBytes limits the size of the payloads the exec methods send to plugins. Larger payloads fail with a
+// *PayloadTooLargeError before they are sent; streamed ones fail once the limit is crossed. Zero, the default, means no
+// limit.
+func WithMaxRequestBytes(limit int64) ClientOption {
+	return func(c *Client) {
+		c.maxRequestBytes = limit
+	}
+}
+
+// execResponseLimit returns the limit for the output of a plugin read by a buffered exec method.
+func (c *Client) execResponseLimit(o execOptions) int64 {
+	switch {
+	case o.maxResponseBytes > 0:
+		return o.maxResponseBytes
+	case c.maxExecResponseBytes > 0:
+		return c.maxExecResponseBytes
+	default:
+		return c.maxResponseBytes
+	}
+}
+
+// execRequestLimit returns the limit for the payload of an execution.
+func (c *Client) execRequestLimit(o execOptions) int64 {
+	if o.maxRequestBytes > 0 {
+		return o.maxRequestBytes
+	}
+
+	return c.maxRequestBytes
+}
+
+// limitRequest checks the size of the request body against the limit if it's known up front, and otherwise wraps it so
+// reading it fails once the limit is crossed. A limit of zero or less means no limit.
+func limitRequest(body io.Reader, limit int64) (io.Reader, error) {
+	if limit <= 0 || body == nil {
+		return body, nil
+	}
+
+	if sized, ok := body.(interface{ Len() int }); ok {
+		if size := int64(sized.Len()); size > limit {
+			return nil, &PayloadTooLargeError{Request: true, Limit: limit, Size: size}
+		}
+
+		return body, nil
+	}
+
+	return newLimitedReader(body, limit, true), nil
+}
+
+// limitResponse fails if the response says it's larger than the limit, and otherwise wraps its body so reading it fails
+// once the limit is crossed. A limit of zero or less means no limit.
+func limitResponse(res *http.Response, limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+
+	if res.ContentLength > limit {
+		return &PayloadTooLargeError{Limit: limit, Size: res.ContentLength}
+	}
+
+	res.Body = newLimitedReader(res.Body, limit, false)
+
+	return nil
+}
+
+// readResponse reads the whole body of the response, but fails once it's larger than the limit.
+func readResponse(res *http.Response, limit int64) ([]byte, error) {
+	err := limitResponse(res, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "io.ReadAll")
+	}
+
+	return b, nil
+}
+
+// limitedReader reads up to limit bytes from the underlying reader, and fails with a *PayloadTooLargeError if there are
+// more. Unlike io.LimitReader, it tells a payload of exactly limit bytes apart from a larger one. Closing it closes the
+// underlying reader if that is an io.Closer.
+type limitedReader struct {
+	r     io.Reader
+	left  int64
+	limit int64
+
+	request bool
+}
+
+// newLimitedReader returns a reader that fails once more than limit bytes were read from r.
+func newLimitedReader(r io.Reader, limit int64, request bool) *limitedReader {
+	return &limitedReader{r: r, left: limit, limit: limit, request: request}
+}
+
+// Read reads from the underlying reader until the limit is reached, after which it checks whether there is more.
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.left <= 0 {
+		var probe [1]byte
+
+		n, err := l.r.Read(probe[:])
+		if n > 0 {
+			return 0, &PayloadTooLargeError{Request: l.request, Limit: l.limit, Size: -1}
+		}
+
+		return 0, err //nolint:wrapcheck
+	}
+
+	if int64(len(p)) > l.left {
+		p = p[:l.left]
+	}
+
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+
+	return n, err //nolint:wrapcheck
+}
+
+// Close closes the underlying reader if it's an io.Closer.
+func (l *limitedReader) Close() error {
+	if closer, ok := l.r.(io.Closer); ok {
+		return closer.Close() //nolint:wrapcheck
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package se2_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+)
+
+// sizedServer responds with n bytes of output to every exec, streamed without a Content-Length if the request has a
+// stream query parameter, and streams a tenant with a description of n bytes to GetTenantByName.
+func sizedServer(t *testing.T, n int) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		if strings.HasPrefix(r.URL.Path, "/environment/v1/tenant/") {
+			w.(http.Flusher).Flush()
+
+			_, _ = w.Write([]byte(`{"authorized_party":"","id":"","environment":"","name":"acme","description":"` + strings.Repeat("a", n) + `"}`))
+
+			return
+		}
+
+		if r.URL.Query().Has("stream") {
+			w.(http.Flusher).Flush()
+		}
+
+		_, _ = w.Write([]byte(strings.Repeat("a", n)))
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestPayloadLimits(t *testing.T) {
+	srv := sizedServer(t, 100)
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		options     []se2.ClientOption
+		call        func(c *se2.Client) error
+		wantRequest bool
+		wantSize    int64
+	}{
+		{
+			name:    "response with content length",
+			options: []se2.ClientOption{se2.WithMaxResponseBytes(99)},
+			call: func(c *se2.Client) error {
+				_, err := c.Exec(ctx, []byte(`hi`), "acme", "default", "big")
+
+				return err
+			},
+			wantSize: 100,
+		},
+		{
+			name:    "streamed response",
+			options: []se2.ClientOption{se2.WithMaxResponseBytes(99)},
+			call: func(c *se2.Client) error {
+				_, err := c.Exec(ctx, []byte(`hi`), "acme", "default", "big", se2.ExecQuery("stream", "1"))
+
+				return err
+			},
+			wantSize: -1,
+		},
+		{
+			name:    "exec limit takes precedence",
+			options: []se2.ClientOption{se2.WithMaxResponseBytes(1000), se2.WithMaxExecResponseBytes(10)},
+			call: func(c *se2.Client) error {
+				_, err := c.ExecRef(ctx, []byte(`hi`), "abc")
+
+				return err
+			},
+			wantSize: 100,
+		},
+		{
+			name:    "per call limit takes precedence",
+			options: []se2.ClientOption{se2.WithMaxResponseBytes(1000)},
+			call: func(c *se2.Client) error {
+				_, err := c.ExecDetailed(ctx, []byte(`hi`), "acme", "default", "big", se2.ExecMaxResponseBytes(10))
+
+				return err
+			},
+			wantSize: 100,
+		},
+		{
+			name: "per call limit on a stream",
+			call: func(c *se2.Client) error {
+				out, err := c.ExecStream(ctx, strings.NewReader(`hi`), "acme", "default", "big",
+					se2.ExecQuery("stream", "1"),
+					se2.ExecMaxResponseBytes(10),
+				)
+				if err != nil {
+					return err
+				}
+
+				defer out.Close()
+
+				_, err = io.ReadAll(out)
+
+				return err
+			},
+			wantSize: -1,
+		},
+		{
+			name:    "admin response",
+			options: []se2.ClientOption{se2.WithMaxResponseBytes(99)},
+			call: func(c *se2.Client) error {
+				_, err := c.GetTenantByName(ctx, "acme")
+
+				return err
+			},
+			wantSize: -1,
+		},
+		{
+			name:    "request payload",
+			options: []se2.ClientOption{se2.WithMaxRequestBytes(4)},
+			call: func(c *se2.Client) error {
+				_, err := c.Exec(ctx, []byte(`hello`), "acme", "default", "big")
+
+				return err
+			},
+			wantRequest: true,
+			wantSize:    5,
+		},
+		{
+			name: "streamed request payload",
+			call: func(c *se2.Client) error {
+				_, err := c.ExecStream(ctx, io.MultiReader(strings.NewReader(`hello`)), "acme", "default", "big", se2.ExecMaxRequestBytes(4))
+
+				return err
+			},
+			wantRequest: true,
+			wantSize:    -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := append([]se2.ClientOption{se2.WithHosts(srv.URL, srv.URL)}, tt.options...)
+
+			client, err := se2.NewClient(se2.ModeCustom, testAccessKey, options...)
+			require.NoError(t, err)
+
+			err = tt.call(client)
+			require.ErrorIs(t, err, se2.ErrPayloadTooLarge)
+
+			var tooLarge *se2.PayloadTooLargeError
+
+			require.ErrorAs(t, err, &tooLarge)
+			assert.Equal(t, tt.wantRequest, tooLarge.Request)
+			assert.Equal(t, tt.wantSize, tooLarge.Size)
+		})
+	}
+}
+
+func TestPayloadLimits_Exact(t *testing.T) {
+	srv := sizedServer(t, 100)
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL, srv.URL),
+		se2.WithMaxResponseBytes(100),
+		se2.WithMaxRequestBytes(5),
+	)
+	require.NoError(t, err)
+
+	for _, stream := range []string{"", "1"} {
+		out, err := client.Exec(context.Background(), []byte(`hello`), "acme", "default", "big", se2.ExecQuery("stream", stream))
+		require.NoError(t, err)
+		assert.Len(t, out, 100)
+	}
+}
@@ -0,0 +1,119 @@
+package se2
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const redacted = "REDACTED"
+
+// WithLogger makes the client log what it does to the logger: requests and responses at debug level, retries at info
+// level, failed requests at warn level, and responses it could not decode at error level. The environment access key
+// and session tokens in the Authorization header are always redacted. By default, the client does not log.
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// log writes a record to the logger, if there is one, with the operation of the context as an attribute.
+func (c *Client) log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if c.logger == nil || !c.logger.Enabled(ctx, level) {
+		return
+	}
+
+	if op, ok := OperationFromContext(ctx); ok {
+		attrs = append(attrs, slog.Any("operation", op))
+	}
+
+	c.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// logRequest logs an attempt of a request at debug level.
+func (c *Client) logRequest(req *http.Request, attempt int) {
+	c.log(req.Context(), slog.LevelDebug, "se2: sending request",
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.String()),
+		slog.Int("attempt", attempt),
+		slog.Any("headers", redactedHeader(req.Header)),
+	)
+}
+
+// logResponse logs the outcome of an attempt: the response at debug level, or the error at warn level.
+func (c *Client) logResponse(req *http.Request, res *http.Response, err error, took time.Duration) {
+	if err != nil {
+		c.log(req.Context(), slog.LevelWarn, "se2: request failed",
+			slog.String("method", req.Method),
+			slog.String("url", req.URL.String()),
+			slog.Duration("took", took),
+			slog.String("error", err.Error()),
+		)
+
+		return
+	}
+
+	c.log(req.Context(), slog.LevelDebug, "se2: received response",
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.String()),
+		slog.Int("status", res.StatusCode),
+		slog.Duration("took", took),
+		slog.String("request_id", requestID(res)),
+	)
+}
+
+// LogValue makes the operation log as a group of its non-empty fields.
+func (o Operation) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, 6)
+
+	for _, kv := range []struct {
+		key   string
+		value string
+	}{
+		{"name", o.Name},
+		{"tenant", o.Tenant},
+		{"namespace", o.Namespace},
+		{"plugin", o.Plugin},
+		{"template", o.Template},
+		{"ref", o.Ref},
+	} {
+		if kv.value != emptyString {
+			attrs = append(attrs, slog.String(kv.key, kv.value))
+		}
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+// redactedHeader is an http.Header that logs with the credentials in it redacted.
+type redactedHeader http.Header
+
+// LogValue implements slog.LogValuer. Only the scheme of the Authorization header is kept.
+func (h redactedHeader) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(h))
+
+	for name, values := range h {
+		value := strings.Join(values, ", ")
+
+		if http.CanonicalHeaderKey(name) == "Authorization" {
+			value = redactAuthorization(value)
+		}
+
+		attrs = append(attrs, slog.String(name, value))
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+// redactAuthorization replaces the credentials in the value of an Authorization header, "Bearer <token>", with a
+// placeholder.
+func redactAuthorization(value string) string {
+	scheme, _, found := strings.Cut(value, " ")
+	if !found {
+		return redacted
+	}
+
+	return scheme + " " + redacted
+}
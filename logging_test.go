@@ -0,0 +1,83 @@
+package se2_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+)
+
+func TestWithLogger(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/environment/v1/tenant/acme/session":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"secret-session-token"}`))
+		case "/builder/v1/draft":
+			_, _ = w.Write([]byte(`not json`))
+		}
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL, srv.URL),
+		se2.WithLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+	require.NoError(t, err)
+
+	session, err := client.CreateSession(context.Background(), "acme", "default", "hello")
+	require.NoError(t, err)
+
+	_, err = client.GetPluginDraft(context.Background(), session)
+	require.Error(t, err)
+
+	logs := buf.String()
+
+	assert.Contains(t, logs, `"msg":"se2: sending request"`)
+	assert.Contains(t, logs, `"msg":"se2: received response"`)
+	assert.Contains(t, logs, `"msg":"se2: could not decode response"`)
+	assert.Contains(t, logs, `"operation":{"name":"client.GetPluginDraft","tenant":"acme","namespace":"default","plugin":"hello"}`)
+	assert.Contains(t, logs, `"Authorization":"Bearer REDACTED"`)
+
+	assert.NotContains(t, logs, testAccessKey)
+	assert.NotContains(t, logs, "secret-session-token")
+}
+
+func TestWithLogger_SyntheticResponse(t *testing.T) {
+	var buf bytes.Buffer
+
+	// The middleware answers without sending the request, and leaves the Request field of the response unset.
+	synthetic := func(next se2.Doer) se2.Doer {
+		return se2.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     make(http.Header),
+				Body:       io.NopCloser(strings.NewReader(`{"bogus":1}`)),
+			}, nil
+		})
+	}
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts("http://localhost", "http://localhost"),
+		se2.WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))),
+		se2.WithMiddleware(synthetic),
+	)
+	require.NoError(t, err)
+
+	_, err = client.ListTemplates(context.Background())
+	require.Error(t, err)
+
+	assert.Contains(t, buf.String(), `"msg":"se2: could not decode response"`)
+	assert.Contains(t, buf.String(), `"operation":{"name":"client.ListTemplates"}`)
+}
@@ -0,0 +1,182 @@
+// Package metrics provides a Prometheus collector for the requests an se2.Client makes.
+package metrics
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/suborbital/se2-go"
+)
+
+const (
+	namespace = "se2"
+	subsystem = "client"
+
+	labelOperation = "operation"
+	labelHost      = "host"
+	labelClass     = "class"
+
+	// classTransport is the class of a request that did not get a response at all.
+	classTransport = "transport"
+)
+
+// Collector counts the requests, their errors, how long they took, and how many are in flight, labelled by the
+// client operation, like "Exec" or "CreateTenant", and the host they were sent to. It implements
+// prometheus.Collector, so it can be registered with any registry, and is wired into a client with Middleware:
+//
+//	collector := metrics.NewCollector()
+//	prometheus.MustRegister(collector)
+//
+//	client, err := se2.NewClient(se2.ModeProduction, token, se2.WithMiddleware(collector.Middleware()))
+//
+// Every attempt of a retried request is counted separately.
+type Collector struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+// Option configures the Collector.
+type Option func(*options)
+
+// options holds the configuration of the Collector.
+type options struct {
+	buckets     []float64
+	constLabels prometheus.Labels
+}
+
+// WithBuckets sets the buckets of the request duration histogram, in seconds. By default, they are
+// prometheus.DefBuckets.
+func WithBuckets(buckets []float64) Option {
+	return func(o *options) {
+		o.buckets = buckets
+	}
+}
+
+// WithConstLabels adds labels with fixed values to every metric, useful to tell several clients apart.
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(o *options) {
+		o.constLabels = labels
+	}
+}
+
+// NewCollector returns a Collector with its metrics created, but not registered.
+func NewCollector(opts ...Option) *Collector {
+	o := options{
+		buckets: prometheus.DefBuckets,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "requests_total",
+			Help:        "Number of requests sent to SE2, by operation, host, and status class of the response.",
+			ConstLabels: o.constLabels,
+		}, []string{labelOperation, labelHost, labelClass}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "request_errors_total",
+			Help:        "Number of requests to SE2 that failed, by operation, host, and class: 4xx, 5xx, or transport.",
+			ConstLabels: o.constLabels,
+		}, []string{labelOperation, labelHost, labelClass}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "request_duration_seconds",
+			Help:        "Time until the response headers of a request to SE2 arrived, by operation and host.",
+			Buckets:     o.buckets,
+			ConstLabels: o.constLabels,
+		}, []string{labelOperation, labelHost}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "requests_in_flight",
+			Help:        "Number of requests to SE2 waiting for a response, by operation and host.",
+			ConstLabels: o.constLabels,
+		}, []string{labelOperation, labelHost}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.errors.Describe(ch)
+	c.duration.Describe(ch)
+	c.inFlight.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.errors.Collect(ch)
+	c.duration.Collect(ch)
+	c.inFlight.Collect(ch)
+}
+
+// Middleware returns the se2.Middleware that records the metrics of every request the client sends.
+func (c *Collector) Middleware() se2.Middleware {
+	return func(next se2.Doer) se2.Doer {
+		return se2.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			operation := operationLabel(req)
+			host := req.URL.Host
+
+			inFlight := c.inFlight.WithLabelValues(operation, host)
+			inFlight.Inc()
+
+			start := time.Now()
+			res, err := next.Do(req)
+
+			c.duration.WithLabelValues(operation, host).Observe(time.Since(start).Seconds())
+			inFlight.Dec()
+
+			class := classTransport
+			if err == nil {
+				class = statusClass(res.StatusCode)
+			}
+
+			c.requests.WithLabelValues(operation, host, class).Inc()
+
+			if err != nil || res.StatusCode >= http.StatusBadRequest {
+				c.errors.WithLabelValues(operation, host, class).Inc()
+			}
+
+			return res, err //nolint:wrapcheck
+		})
+	}
+}
+
+// operationLabel returns the name of the client method that made the request without the "client." prefix.
+func operationLabel(req *http.Request) string {
+	op, ok := se2.OperationFromContext(req.Context())
+	if !ok || op.Name == "" {
+		return "unknown"
+	}
+
+	return strings.TrimPrefix(op.Name, "client.")
+}
+
+// statusClass returns the class of the status code, like "2xx".
+func statusClass(code int) string {
+	switch {
+	case code >= 500:
+		return "5xx"
+	case code >= 400:
+		return "4xx"
+	case code >= 300:
+		return "3xx"
+	case code >= 200:
+		return "2xx"
+	default:
+		return "1xx"
+	}
+}
@@ -0,0 +1,67 @@
+package metrics_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+	"github.com/suborbital/se2-go/metrics"
+)
+
+const testAccessKey = "eyJrZXkiOjQwNywic2VjcmV0IjoiZWsvNFV3VTBnZ2VHUjdQanF1MmlyaWJacGR1MXZvcWNhMXl3eDE3aWhpTT0ifQ=="
+
+func TestCollector(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/name/acme/default/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`ok`))
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	collector := metrics.NewCollector()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL, srv.URL),
+		se2.WithMiddleware(collector.Middleware()),
+	)
+	require.NoError(t, err)
+
+	_, err = client.Exec(context.Background(), []byte(`hi`), "acme", "default", "hello")
+	require.NoError(t, err)
+
+	_, err = client.Exec(context.Background(), []byte(`hi`), "acme", "default", "broken")
+	require.Error(t, err)
+
+	expected := `
+# HELP se2_client_request_errors_total Number of requests to SE2 that failed, by operation, host, and class: 4xx, 5xx, or transport.
+# TYPE se2_client_request_errors_total counter
+se2_client_request_errors_total{class="5xx",host="HOST",operation="Exec"} 1
+# HELP se2_client_requests_in_flight Number of requests to SE2 waiting for a response, by operation and host.
+# TYPE se2_client_requests_in_flight gauge
+se2_client_requests_in_flight{host="HOST",operation="Exec"} 0
+# HELP se2_client_requests_total Number of requests sent to SE2, by operation, host, and status class of the response.
+# TYPE se2_client_requests_total counter
+se2_client_requests_total{class="2xx",host="HOST",operation="Exec"} 1
+se2_client_requests_total{class="5xx",host="HOST",operation="Exec"} 1
+`
+
+	err = testutil.CollectAndCompare(collector, strings.NewReader(strings.ReplaceAll(expected, "HOST", u.Host)),
+		"se2_client_requests_total", "se2_client_request_errors_total", "se2_client_requests_in_flight")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "se2_client_request_duration_seconds"))
+}
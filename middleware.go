@@ -0,0 +1,72 @@
+package se2
+
+import (
+	"context"
+	"net/http"
+)
+
+// operationContextKey is the key the Operation of a request is stored under in the request's context.
+type operationContextKey struct{}
+
+// Operation describes the client method a request is made by, and what it operates on. Fields that do not apply to the
+// method are left empty.
+type Operation struct {
+	// Name is the name of the client method, for example "client.BuildPlugin".
+	Name string
+
+	Tenant    string
+	Namespace string
+	Plugin    string
+	Template  string
+
+	// Ref is the ref of the plugin version, for methods that work on a specific version.
+	Ref string
+}
+
+// OperationFromContext returns the Operation stored in the context. Middleware can use it with the request's context
+// to find out which client method made the request.
+func OperationFromContext(ctx context.Context) (Operation, bool) {
+	op, ok := ctx.Value(operationContextKey{}).(Operation)
+
+	return op, ok
+}
+
+// withOperation returns a copy of the context that carries the operation.
+func withOperation(ctx context.Context, op Operation) context.Context {
+	return context.WithValue(ctx, operationContextKey{}, op)
+}
+
+// Doer sends an http request and returns its response. *http.Client is a Doer.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc is an adapter to allow the use of ordinary functions as a Doer.
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+// Do calls f(req).
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps a Doer with additional behavior, like decorating the request, or auditing the response.
+type Middleware func(next Doer) Doer
+
+// WithMiddleware adds middleware around the http client of the Client. The middleware is applied in the order it is
+// passed in, so the first one is the outermost, and sees the request first. It runs for every attempt of a request,
+// after the authorization header is set and after waiting for the rate limiter, for both environment and session token
+// requests. The request's context carries the Operation, see OperationFromContext.
+func WithMiddleware(middleware ...Middleware) ClientOption {
+	return func(c *Client) {
+		c.middleware = append(c.middleware, middleware...)
+	}
+}
+
+// chain wraps the doer in the middleware, so that the first middleware is the outermost.
+func chain(doer Doer, middleware []Middleware) Doer {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		doer = middleware[i](doer)
+	}
+
+	return doer
+}
@@ -0,0 +1,75 @@
+package se2_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+)
+
+func TestWithMiddleware(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "outer,inner", r.Header.Get("X-Trail"))
+
+		switch r.URL.Path {
+		case "/environment/v1/tenant/acme/session":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"session-token"}`))
+		default:
+			assert.Equal(t, "Bearer session-token", r.Header.Get("Authorization"))
+			_, _ = w.Write([]byte(`{"lang":"js","contents":""}`))
+		}
+	}))
+	defer srv.Close()
+
+	var ops []se2.Operation
+
+	trail := func(name string) se2.Middleware {
+		return func(next se2.Doer) se2.Doer {
+			return se2.DoerFunc(func(req *http.Request) (*http.Response, error) {
+				value := name
+				if current := req.Header.Get("X-Trail"); current != "" {
+					value = current + "," + name
+				}
+
+				req.Header.Set("X-Trail", value)
+
+				return next.Do(req)
+			})
+		}
+	}
+
+	record := func(next se2.Doer) se2.Doer {
+		return se2.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			op, ok := se2.OperationFromContext(req.Context())
+			assert.True(t, ok)
+
+			ops = append(ops, op)
+
+			return next.Do(req)
+		})
+	}
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL, srv.URL),
+		se2.WithMiddleware(record, trail("outer")),
+		se2.WithMiddleware(trail("inner")),
+	)
+	require.NoError(t, err)
+
+	session, err := client.CreateSession(context.Background(), "acme", "default", "hello")
+	require.NoError(t, err)
+
+	_, err = client.GetPluginDraft(context.Background(), session)
+	require.NoError(t, err)
+
+	assert.Equal(t, []se2.Operation{
+		{Name: "client.CreateSession", Tenant: "acme", Namespace: "default", Plugin: "hello"},
+		{Name: "client.GetPluginDraft", Tenant: "acme", Namespace: "default", Plugin: "hello"},
+	}, ops)
+}
@@ -2,7 +2,6 @@ package se2
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 
@@ -25,12 +24,19 @@ type PluginResponse struct {
 	Plugins []Plugin `json:"plugins"`
 }
 
-func (c *Client) GetPlugins(ctx context.Context, tenantName string) (PluginResponse, error) {
+func (c *Client) GetPlugins(ctx context.Context, tenantName string) (_ PluginResponse, err error) {
 	if tenantName == emptyString {
 		return PluginResponse{}, errors.New("client.GetPlugins: tenant name cannot be blank")
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(c.host+pathPlugins, tenantName), nil)
+	op := Operation{Name: "client.GetPlugins", Tenant: tenantName}
+
+	ctx, span := c.startSpan(ctx, op)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	req, err := http.NewRequestWithContext(withOperation(ctx, op), http.MethodGet, fmt.Sprintf(c.host+pathPlugins, tenantName), nil)
 	if err != nil {
 		return PluginResponse{}, errors.Wrap(err, "client.GetPlugins: http.NewRequest")
 	}
@@ -45,18 +51,17 @@ func (c *Client) GetPlugins(ctx context.Context, tenantName string) (PluginRespo
 	}()
 
 	if res.StatusCode != http.StatusOK {
-		return PluginResponse{}, fmt.Errorf(httpResponseCodeErrorFormat, "client.GetPlugins", http.StatusOK, res.StatusCode)
+		return PluginResponse{}, newAPIError("client.GetPlugins", http.StatusOK, res)
 	}
 
 	var t PluginResponse
 
-	dec := json.NewDecoder(res.Body)
-	dec.DisallowUnknownFields()
-
-	err = dec.Decode(&t)
+	err = c.decode(req.Context(), res, &t)
 	if err != nil {
-		return PluginResponse{}, errors.Wrap(err, "client.GetPlugins: dec.Decode")
+		return PluginResponse{}, errors.Wrap(err, "client.GetPlugins: c.decode")
 	}
 
+	c.execCache.observePlugins(tenantName, t.Plugins)
+
 	return t, nil
 }
@@ -0,0 +1,192 @@
+package se2
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RateLimit describes a token bucket: it refills at RequestsPerSecond, and holds at most Burst tokens. Every request
+// takes one token, and waits for it if the bucket is empty. The zero value means no limit.
+type RateLimit struct {
+	RequestsPerSecond float64
+	Burst             int
+}
+
+// LimiterStats is a snapshot of the state of a single rate limiter.
+type LimiterStats struct {
+	// Enabled is false if no rate limit has been configured for the host.
+	Enabled bool
+
+	// RequestsPerSecond and Burst are the configured limits.
+	RequestsPerSecond float64
+	Burst             int
+
+	// Available is the number of tokens in the bucket right now. It's negative while there are requests waiting.
+	Available float64
+
+	// Waits is the number of requests that had to wait for a token, and TotalWait is the sum of those waits.
+	Waits     uint64
+	TotalWait time.Duration
+}
+
+// RateLimiterStats holds the snapshots of the limiters of the admin host and the exec host.
+type RateLimiterStats struct {
+	Admin LimiterStats
+	Exec  LimiterStats
+}
+
+// WithRateLimit limits how often the client sends requests to the SE2 hosts. The admin host (tenants, templates,
+// sessions, and the builder) and the exec host (the edge that runs plugins) each get their own bucket, so heavy
+// execution doesn't starve administration, or the other way around. Waiting for a token respects the request's
+// context. Pass the zero value RateLimit to leave a host unlimited.
+func WithRateLimit(admin, exec RateLimit) ClientOption {
+	return func(c *Client) {
+		c.adminLimiter = newTokenBucket(admin)
+		c.execLimiter = newTokenBucket(exec)
+	}
+}
+
+// RateLimiterStats returns the current state of the rate limiters configured with WithRateLimit.
+func (c *Client) RateLimiterStats() RateLimiterStats {
+	return RateLimiterStats{
+		Admin: c.adminLimiter.stats(),
+		Exec:  c.execLimiter.stats(),
+	}
+}
+
+// execHostContextKey marks the context of a request that is sent to the exec host.
+type execHostContextKey struct{}
+
+// withExecHost returns a copy of the context that marks the request as one for the exec host.
+func withExecHost(ctx context.Context) context.Context {
+	return context.WithValue(ctx, execHostContextKey{}, true)
+}
+
+// limiterFor returns the limiter for the host the request is sent to. It can be nil, which means no limit. The host is
+// not told apart by the URL of the request, because the admin URL can start with the exec URL, like when both are
+// served by the same server.
+func (c *Client) limiterFor(req *http.Request) *tokenBucket {
+	if exec, _ := req.Context().Value(execHostContextKey{}).(bool); exec {
+		return c.execLimiter
+	}
+
+	return c.adminLimiter
+}
+
+// tokenBucket is a token bucket rate limiter. Requests reserve tokens ahead of time, which lets the bucket go negative,
+// so that waiters are served in order without having to poll.
+type tokenBucket struct {
+	mu sync.Mutex
+
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	waits  uint64
+	waited time.Duration
+}
+
+// newTokenBucket returns a full bucket for the limit, or nil if the limit is the zero value.
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	if limit.RequestsPerSecond <= 0 {
+		return nil
+	}
+
+	burst := math.Max(float64(limit.Burst), 1)
+
+	return &tokenBucket{
+		rate:   limit.RequestsPerSecond,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// advance adds the tokens accumulated since the last call. It needs to be called with the lock held.
+func (b *tokenBucket) advance(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+// wait takes a token from the bucket, blocking until one is available or the context is done. If the context's
+// deadline would pass before the token becomes available, it returns right away without taking one.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+
+	now := time.Now()
+	b.advance(now)
+
+	b.tokens--
+
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && delay > 0 && now.Add(delay).After(deadline) {
+		b.tokens++
+		b.mu.Unlock()
+
+		return errors.Wrap(context.DeadlineExceeded, "rate limit wait would exceed context deadline")
+	}
+
+	if delay > 0 {
+		b.waits++
+		b.waited += delay
+	}
+
+	b.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		// Give the reserved token back so the requests queued behind this one don't wait for nothing.
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+
+		return errors.Wrap(ctx.Err(), "waiting for rate limit")
+	case <-timer.C:
+		return nil
+	}
+}
+
+// stats returns a snapshot of the bucket.
+func (b *tokenBucket) stats() LimiterStats {
+	if b == nil {
+		return LimiterStats{}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance(time.Now())
+
+	return LimiterStats{
+		Enabled:           true,
+		RequestsPerSecond: b.rate,
+		Burst:             int(b.burst),
+		Available:         b.tokens,
+		Waits:             b.waits,
+		TotalWait:         b.waited,
+	}
+}
@@ -0,0 +1,100 @@
+package se2_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+)
+
+func TestWithRateLimit(t *testing.T) {
+	admin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"templates":[]}`))
+	}))
+	defer admin.Close()
+
+	edge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`ok`))
+	}))
+	defer edge.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(admin.URL, edge.URL),
+		se2.WithRateLimit(se2.RateLimit{}, se2.RateLimit{RequestsPerSecond: 50, Burst: 1}),
+	)
+	require.NoError(t, err)
+
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		_, err = client.Exec(context.Background(), []byte(`hi`), "acme", "default", "hello")
+		require.NoError(t, err)
+
+		_, err = client.ListTemplates(context.Background())
+		require.NoError(t, err)
+	}
+
+	// Two of the three execs had to wait for 20ms each.
+	assert.GreaterOrEqual(t, time.Since(start), 35*time.Millisecond)
+
+	stats := client.RateLimiterStats()
+	assert.False(t, stats.Admin.Enabled)
+	assert.True(t, stats.Exec.Enabled)
+	assert.Equal(t, uint64(2), stats.Exec.Waits)
+}
+
+func TestWithRateLimit_Deadline(t *testing.T) {
+	edge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`ok`))
+	}))
+	defer edge.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(edge.URL, edge.URL),
+		se2.WithRateLimit(se2.RateLimit{}, se2.RateLimit{RequestsPerSecond: 0.01, Burst: 1}),
+	)
+	require.NoError(t, err)
+
+	_, err = client.Exec(context.Background(), []byte(`hi`), "acme", "default", "hello")
+	require.NoError(t, err)
+
+	ctx, cxl := context.WithTimeout(context.Background(), time.Second)
+	defer cxl()
+
+	// The next token is 100 seconds away, so the client should not even start waiting.
+	_, err = client.Exec(ctx, []byte(`hi`), "acme", "default", "hello")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, uint64(0), client.RateLimiterStats().Exec.Waits)
+}
+
+func TestWithRateLimit_SharedServer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"templates":[]}`))
+	}))
+	defer srv.Close()
+
+	// The admin URL starts with the exec URL, so the host can't be told apart by the URL.
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL+"/admin", srv.URL),
+		se2.WithRateLimit(se2.RateLimit{RequestsPerSecond: 50, Burst: 1}, se2.RateLimit{}),
+	)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err = client.ListTemplates(context.Background())
+		require.NoError(t, err)
+
+		_, err = client.Exec(context.Background(), []byte(`hi`), "acme", "default", "hello")
+		require.NoError(t, err)
+	}
+
+	stats := client.RateLimiterStats()
+	assert.Equal(t, uint64(2), stats.Admin.Waits)
+	assert.False(t, stats.Exec.Enabled)
+}
@@ -0,0 +1,258 @@
+package se2
+
+import (
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = 200 * time.Millisecond
+	defaultRetryMaxBackoff     = 5 * time.Second
+	defaultRetryMultiplier     = 2
+	defaultRetryJitter         = 0.2
+	defaultRetryMaxRetryAfter  = 30 * time.Second
+
+	// maxDrainBytes is how much of a discarded response body we read so the underlying connection can be reused.
+	maxDrainBytes = 4 << 10
+)
+
+// RetryPolicy configures how the client retries failed requests. Requests that can safely be repeated, the GET calls
+// like GetTenantByName, ListTenants, GetPlugins, ListTemplates, and GetPluginDraft, and executions with an
+// ExecIdempotencyKey, are retried when the request could not be made at all, or when the server responds with 429, 502,
+// 503, or 504. Every other request is only retried on a 429 response, because in that case the server did not act on
+// it.
+//
+// Waits between attempts grow exponentially from InitialBackoff up to MaxBackoff. A Retry-After header on the response
+// is honored if it asks for a longer wait, unless it is longer than MaxRetryAfter, in which case the response is
+// returned as is.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one. 1 or less disables retries.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry, MaxBackoff caps the wait between any two attempts.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Multiplier is the factor the wait grows by after each attempt. Values below 1 are treated as 1.
+	Multiplier float64
+
+	// Jitter is the fraction, between 0 and 1, of each wait that is randomized to keep clients from retrying in sync.
+	Jitter float64
+
+	// MaxRetryAfter is the longest Retry-After the client is willing to wait for. Zero means no limit.
+	MaxRetryAfter time.Duration
+}
+
+// DefaultRetryPolicy returns a policy that makes up to 3 attempts with waits starting at 200ms, capped at 5 seconds.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    defaultRetryMaxAttempts,
+		InitialBackoff: defaultRetryInitialBackoff,
+		MaxBackoff:     defaultRetryMaxBackoff,
+		Multiplier:     defaultRetryMultiplier,
+		Jitter:         defaultRetryJitter,
+		MaxRetryAfter:  defaultRetryMaxRetryAfter,
+	}
+}
+
+// WithRetryPolicy configures the client to retry failed requests according to the policy. By default, the client
+// makes every request exactly once.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retryPolicy = policy
+	}
+}
+
+// backoff returns how long to wait after the given attempt failed. Attempts are counted from 1.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := math.Max(p.Multiplier, 1)
+
+	d := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 {
+		d = math.Min(d, float64(p.MaxBackoff))
+	}
+
+	jitter := math.Min(math.Max(p.Jitter, 0), 1)
+
+	// Jitter does not need to be cryptographically secure.
+	d -= d * jitter * rand.Float64() //nolint:gosec
+
+	return time.Duration(d)
+}
+
+// sendWithRetries does the request against the http client wrapped in the middleware, retrying it according to the
+// configured retry policy. Every attempt waits for the rate limiter of the host it's sent to.
+func (c *Client) sendWithRetries(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	idempotent := isIdempotent(req)
+
+	for attempt := 1; ; attempt++ {
+		r, err := requestForAttempt(req, attempt)
+		if err != nil {
+			return nil, errors.Wrap(err, "requestForAttempt")
+		}
+
+		err = c.limiterFor(r).wait(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "limiter.wait")
+		}
+
+		r, span := c.startAttempt(r, attempt)
+
+		c.logRequest(r, attempt)
+
+		start := time.Now()
+		res, err := c.doer.Do(r)
+
+		c.logResponse(r, res, err, time.Since(start))
+		endAttempt(span, res, err)
+
+		// Stop if we're out of attempts, the caller gave up, or the outcome is not worth another try.
+		if attempt >= c.retryPolicy.MaxAttempts || ctx.Err() != nil || !shouldRetry(res, err, idempotent) || !canRewind(req) {
+			if err != nil {
+				return nil, errors.Wrap(&transportError{err: err}, "c.doer.Do")
+			}
+
+			return res, nil
+		}
+
+		wait := c.retryPolicy.backoff(attempt)
+
+		if res != nil {
+			retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After"))
+			if ok && c.retryPolicy.MaxRetryAfter > 0 && retryAfter > c.retryPolicy.MaxRetryAfter {
+				return res, nil
+			}
+
+			if retryAfter > wait {
+				wait = retryAfter
+			}
+
+			drainAndClose(res)
+		}
+
+		c.log(ctx, slog.LevelInfo, "se2: retrying request",
+			slog.String("method", req.Method),
+			slog.String("url", req.URL.String()),
+			slog.Int("next_attempt", attempt+1),
+			slog.Duration("wait", wait),
+		)
+
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+			attribute.Int("se2.attempt", attempt+1),
+			attribute.Int64("se2.backoff_ms", wait.Milliseconds()),
+		))
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return nil, errors.Wrap(ctx.Err(), "waiting to retry")
+		case <-timer.C:
+		}
+	}
+}
+
+// transportError is an error of sending a request, as opposed to one the client ran into before it got to send it, like
+// a missing access key, or a rate limit wait that would take too long.
+type transportError struct {
+	err error
+}
+
+// Error implements the error interface.
+func (e *transportError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error of the doer.
+func (e *transportError) Unwrap() error {
+	return e.err
+}
+
+// isIdempotent reports whether the request can be made more than once without changing the outcome: it's a read, or
+// the caller gave it an idempotency key.
+func isIdempotent(req *http.Request) bool {
+	return req.Method == http.MethodGet || req.Method == http.MethodHead || req.Header.Get(headerIdempotencyKey) != emptyString
+}
+
+// shouldRetry decides whether the outcome of an attempt warrants another one.
+func shouldRetry(res *http.Response, err error, idempotent bool) bool {
+	if err != nil {
+		return idempotent
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent
+	default:
+		return false
+	}
+}
+
+// canRewind reports whether the body of the request can be sent again.
+func canRewind(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// requestForAttempt returns the original request for the first attempt, and a copy with a fresh body for every other.
+func requestForAttempt(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 1 {
+		return req, nil
+	}
+
+	r := req.Clone(req.Context())
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, errors.Wrap(err, "req.GetBody")
+		}
+
+		r.Body = body
+	}
+
+	return r, nil
+}
+
+// parseRetryAfter reads the value of a Retry-After header, which is either a number of seconds, or an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == emptyString {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	d := time.Until(t)
+	if d < 0 {
+		d = 0
+	}
+
+	return d, true
+}
+
+// drainAndClose discards the rest of a response we're not going to use, so the connection can be reused.
+func drainAndClose(res *http.Response) {
+	_, _ = io.CopyN(io.Discard, res.Body, maxDrainBytes)
+	_ = res.Body.Close()
+}
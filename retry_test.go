@@ -0,0 +1,131 @@
+package se2_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	policy := se2.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Multiplier:     2,
+	}
+
+	tests := []struct {
+		name      string
+		failures  int32
+		status    int
+		call      func(*se2.Client) error
+		wantHits  int32
+		wantError assert.ErrorAssertionFunc
+	}{
+		{
+			name:     "idempotent call recovers after two 503s",
+			failures: 2,
+			status:   http.StatusServiceUnavailable,
+			call: func(c *se2.Client) error {
+				_, err := c.ListTemplates(context.Background())
+
+				return err
+			},
+			wantHits:  3,
+			wantError: assert.NoError,
+		},
+		{
+			name:     "idempotent call gives up after max attempts",
+			failures: 5,
+			status:   http.StatusBadGateway,
+			call: func(c *se2.Client) error {
+				_, err := c.ListTemplates(context.Background())
+
+				return err
+			},
+			wantHits:  3,
+			wantError: assert.Error,
+		},
+		{
+			name:     "non idempotent call is not retried on 503",
+			failures: 1,
+			status:   http.StatusServiceUnavailable,
+			call: func(c *se2.Client) error {
+				return c.ImportTemplatesFromGitHub(context.Background(), "suborbital/templates", "main", ".")
+			},
+			wantHits:  1,
+			wantError: assert.Error,
+		},
+		{
+			name:     "non idempotent call is retried with its body on 429",
+			failures: 1,
+			status:   http.StatusTooManyRequests,
+			call: func(c *se2.Client) error {
+				return c.ImportTemplatesFromGitHub(context.Background(), "suborbital/templates", "main", ".")
+			},
+			wantHits:  2,
+			wantError: assert.NoError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits int32
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&hits, 1) <= tt.failures {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(tt.status)
+
+					return
+				}
+
+				if r.Method == http.MethodPost {
+					assert.NotZero(t, r.ContentLength)
+					w.WriteHeader(http.StatusCreated)
+
+					return
+				}
+
+				_, _ = w.Write([]byte(`{"templates":[]}`))
+			}))
+			defer srv.Close()
+
+			client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+				se2.WithHosts(srv.URL, srv.URL),
+				se2.WithRetryPolicy(policy),
+			)
+			require.NoError(t, err)
+
+			tt.wantError(t, tt.call(client))
+			assert.Equal(t, tt.wantHits, atomic.LoadInt32(&hits))
+		})
+	}
+}
+
+func TestRetryPolicy_ContextCancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL, srv.URL),
+		se2.WithRetryPolicy(se2.RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Hour}),
+	)
+	require.NoError(t, err)
+
+	ctx, cxl := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cxl()
+
+	_, err = client.ListTenants(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -23,11 +23,27 @@ type createSessionRequest struct {
 // methods will require one of their parameters to be of this type.
 type CreateSessionResponse struct {
 	Token string `json:"token"`
+
+	// tenant, namespace, and plugin remember what the session was created for, so requests made with the token can
+	// report it in their Operation. They are empty if the struct was not returned by CreateSession.
+	tenant    string
+	namespace string
+	plugin    string
+}
+
+// operation returns the Operation for a request made with the session token by the named client method.
+func (s CreateSessionResponse) operation(name string) Operation {
+	return Operation{
+		Name:      name,
+		Tenant:    s.tenant,
+		Namespace: s.namespace,
+		Plugin:    s.plugin,
+	}
 }
 
 // CreateSession will create a session for a given tenant, namespace, and plugin to be used in the builder. You should
 // keep track of the return argument and reuse it in later requests.
-func (c *Client) CreateSession(ctx context.Context, tenantName, namespace, plugin string) (CreateSessionResponse, error) {
+func (c *Client) CreateSession(ctx context.Context, tenantName, namespace, plugin string) (_ CreateSessionResponse, err error) {
 	// Check arguments.
 	if tenantName == emptyString {
 		return CreateSessionResponse{}, errors.New("client.CreateSession: tenant name cannot be blank")
@@ -44,7 +60,7 @@ func (c *Client) CreateSession(ctx context.Context, tenantName, namespace, plugi
 	// Build a body, Dr. Frankenstein!
 	var body bytes.Buffer
 
-	err := json.NewEncoder(&body).Encode(createSessionRequest{
+	err = json.NewEncoder(&body).Encode(createSessionRequest{
 		Plugin:    plugin,
 		Namespace: namespace,
 	})
@@ -52,8 +68,15 @@ func (c *Client) CreateSession(ctx context.Context, tenantName, namespace, plugi
 		return CreateSessionResponse{}, errors.Wrap(err, "client.CreateSession: json.NewEncoder().Encode")
 	}
 
+	op := Operation{Name: "client.CreateSession", Tenant: tenantName, Namespace: namespace, Plugin: plugin}
+
+	ctx, span := c.startSpan(ctx, op)
+	defer func() {
+		endSpan(span, err)
+	}()
+
 	// Create the request with the body.
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf(c.host+pathCreateTenantSession, tenantName), &body)
+	req, err := http.NewRequestWithContext(withOperation(ctx, op), http.MethodPost, fmt.Sprintf(c.host+pathCreateTenantSession, tenantName), &body)
 	if err != nil {
 		return CreateSessionResponse{}, errors.Wrap(err, "client.CreateSession: http.NewRequest")
 	}
@@ -70,19 +93,20 @@ func (c *Client) CreateSession(ctx context.Context, tenantName, namespace, plugi
 
 	// Check response code.
 	if res.StatusCode != http.StatusCreated {
-		return CreateSessionResponse{}, fmt.Errorf(httpResponseCodeErrorFormat, "client.CreateSession", http.StatusCreated, res.StatusCode)
+		return CreateSessionResponse{}, newAPIError("client.CreateSession", http.StatusCreated, res)
 	}
 
 	// Marshal response body into what we need to give back.
 	var t CreateSessionResponse
 
-	dec := json.NewDecoder(res.Body)
-	dec.DisallowUnknownFields()
-
-	err = dec.Decode(&t)
+	err = c.decode(req.Context(), res, &t)
 	if err != nil {
-		return CreateSessionResponse{}, errors.Wrap(err, "client.CreateSession: dec.Decode")
+		return CreateSessionResponse{}, errors.Wrap(err, "client.CreateSession: c.decode")
 	}
 
+	t.tenant = tenantName
+	t.namespace = namespace
+	t.plugin = plugin
+
 	return t, nil
 }
@@ -31,8 +31,15 @@ type Template struct {
 
 // ListTemplates will return a ListTemplatesResponse which contains a slice of Template that are available to the
 // environment specified by the API key of the client.
-func (c *Client) ListTemplates(ctx context.Context) (ListTemplatesResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.host+pathTemplate, nil)
+func (c *Client) ListTemplates(ctx context.Context) (_ ListTemplatesResponse, err error) {
+	op := Operation{Name: "client.ListTemplates"}
+
+	ctx, span := c.startSpan(ctx, op)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	req, err := http.NewRequestWithContext(withOperation(ctx, op), http.MethodGet, c.host+pathTemplate, nil)
 	if err != nil {
 		return ListTemplatesResponse{}, errors.Wrap(err, "client.ListTemplates: http.NewRequest")
 	}
@@ -47,17 +54,14 @@ func (c *Client) ListTemplates(ctx context.Context) (ListTemplatesResponse, erro
 	}()
 
 	if res.StatusCode != http.StatusOK {
-		return ListTemplatesResponse{}, fmt.Errorf(httpResponseCodeErrorFormat, "client.ListTemplates", http.StatusOK, res.StatusCode)
+		return ListTemplatesResponse{}, newAPIError("client.ListTemplates", http.StatusOK, res)
 	}
 
 	var t ListTemplatesResponse
 
-	dec := json.NewDecoder(res.Body)
-	dec.DisallowUnknownFields()
-
-	err = dec.Decode(&t)
+	err = c.decode(req.Context(), res, &t)
 	if err != nil {
-		return ListTemplatesResponse{}, errors.Wrap(err, "client.ListTemplates: dec.Decode")
+		return ListTemplatesResponse{}, errors.Wrap(err, "client.ListTemplates: c.decode")
 	}
 
 	return t, nil
@@ -65,12 +69,19 @@ func (c *Client) ListTemplates(ctx context.Context) (ListTemplatesResponse, erro
 
 // GetTemplate takes a name and will return information about a template by that name, or an error if no templates are
 // found.
-func (c *Client) GetTemplate(ctx context.Context, name string) (Template, error) {
+func (c *Client) GetTemplate(ctx context.Context, name string) (_ Template, err error) {
 	if name == emptyString {
 		return Template{}, errors.New("client.GetTemplate: name cannot be blank")
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(c.host+pathTemplateByName, name), nil)
+	op := Operation{Name: "client.GetTemplate", Template: name}
+
+	ctx, span := c.startSpan(ctx, op)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	req, err := http.NewRequestWithContext(withOperation(ctx, op), http.MethodGet, fmt.Sprintf(c.host+pathTemplateByName, name), nil)
 	if err != nil {
 		return Template{}, errors.Wrap(err, "client.GetTemplate: http.NewRequest")
 	}
@@ -85,17 +96,14 @@ func (c *Client) GetTemplate(ctx context.Context, name string) (Template, error)
 	}()
 
 	if res.StatusCode != http.StatusOK {
-		return Template{}, fmt.Errorf(httpResponseCodeErrorFormat, "client.GetTemplate", http.StatusOK, res.StatusCode)
+		return Template{}, newAPIError("client.GetTemplate", http.StatusOK, res)
 	}
 
 	var t Template
 
-	dec := json.NewDecoder(res.Body)
-	dec.DisallowUnknownFields()
-
-	err = dec.Decode(&t)
+	err = c.decode(req.Context(), res, &t)
 	if err != nil {
-		return Template{}, errors.Wrap(err, "client.GetTemplate: dec.Decode")
+		return Template{}, errors.Wrap(err, "client.GetTemplate: c.decode")
 	}
 
 	return t, nil
@@ -125,7 +133,7 @@ type importParams struct {
 //
 // The repository needs to be publicly accessible; private repositories are not supported. Right now only GitHub is the
 // only available provider we can pull source code from.
-func (c *Client) ImportTemplatesFromGitHub(ctx context.Context, repo, ref, path string) error {
+func (c *Client) ImportTemplatesFromGitHub(ctx context.Context, repo, ref, path string) (err error) {
 	if repo == emptyString {
 		return errors.New("client.ImportTemplatesFromGitHub: repo cannot be blank")
 	}
@@ -140,7 +148,7 @@ func (c *Client) ImportTemplatesFromGitHub(ctx context.Context, repo, ref, path
 
 	var requestBody bytes.Buffer
 
-	err := json.NewEncoder(&requestBody).Encode(importRequest{
+	err = json.NewEncoder(&requestBody).Encode(importRequest{
 		Source: "git",
 		Params: importParams{
 			Repo: repo,
@@ -152,7 +160,14 @@ func (c *Client) ImportTemplatesFromGitHub(ctx context.Context, repo, ref, path
 		return errors.Wrap(err, "client.ImportTemplatesFromGitHub: json.NewEncoder.Encode")
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.host+pathTemplateImport, &requestBody)
+	op := Operation{Name: "client.ImportTemplatesFromGitHub"}
+
+	ctx, span := c.startSpan(ctx, op)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	req, err := http.NewRequestWithContext(withOperation(ctx, op), http.MethodPost, c.host+pathTemplateImport, &requestBody)
 	if err != nil {
 		return errors.Wrap(err, "client.ImportTemplatesFromGitHub: http.NewRequest")
 	}
@@ -167,7 +182,7 @@ func (c *Client) ImportTemplatesFromGitHub(ctx context.Context, repo, ref, path
 	}()
 
 	if res.StatusCode != http.StatusCreated {
-		return fmt.Errorf(httpResponseCodeErrorFormat, "client.ImportTemplatesFromGitHub", http.StatusOK, res.StatusCode)
+		return newAPIError("client.ImportTemplatesFromGitHub", http.StatusCreated, res)
 	}
 
 	return nil
@@ -0,0 +1,76 @@
+package se2
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoTenant is returned by the FromContext methods when the context does not carry a tenant, see WithTenant.
+var ErrNoTenant = errors.New("no tenant in the context")
+
+// tenantContextKey is the key the tenant is stored under in a context.
+type tenantContextKey struct{}
+
+// WithTenant returns a copy of the context that carries the ident of a tenant, for services that determine the tenant
+// from the inbound request, like in a middleware, rather than at the call site. The FromContext methods of the client
+// read it back.
+func WithTenant(ctx context.Context, ident string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, ident)
+}
+
+// TenantFromContext returns the ident of the tenant stored in the context with WithTenant. It returns false if there is
+// none, or it's blank.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	ident, _ := ctx.Value(tenantContextKey{}).(string)
+	if strings.TrimSpace(ident) == emptyString {
+		return emptyString, false
+	}
+
+	return ident, true
+}
+
+// tenantFromContext returns the tenant in the context, or an error that matches ErrNoTenant prefixed with the name of
+// the method.
+func tenantFromContext(ctx context.Context, method string) (string, error) {
+	ident, ok := TenantFromContext(ctx)
+	if !ok {
+		return emptyString, errors.Wrap(ErrNoTenant, method)
+	}
+
+	return ident, nil
+}
+
+// ExecFromContext works like Exec for the tenant in the context, see WithTenant. If the context does not carry a
+// tenant, it returns an error that matches ErrNoTenant without running anything.
+func (c *Client) ExecFromContext(ctx context.Context, payload []byte, namespace, plugin string, opts ...ExecOption) ([]byte, error) {
+	ident, err := tenantFromContext(ctx, "client.ExecFromContext")
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Exec(ctx, payload, ident, namespace, plugin, opts...)
+}
+
+// GetPluginsFromContext works like GetPlugins for the tenant in the context, see WithTenant. If the context does not
+// carry a tenant, it returns an error that matches ErrNoTenant.
+func (c *Client) GetPluginsFromContext(ctx context.Context) (PluginResponse, error) {
+	ident, err := tenantFromContext(ctx, "client.GetPluginsFromContext")
+	if err != nil {
+		return PluginResponse{}, err
+	}
+
+	return c.GetPlugins(ctx, ident)
+}
+
+// CreateSessionFromContext works like CreateSession for the tenant in the context, see WithTenant. If the context does
+// not carry a tenant, it returns an error that matches ErrNoTenant.
+func (c *Client) CreateSessionFromContext(ctx context.Context, namespace, plugin string) (CreateSessionResponse, error) {
+	ident, err := tenantFromContext(ctx, "client.CreateSessionFromContext")
+	if err != nil {
+		return CreateSessionResponse{}, err
+	}
+
+	return c.CreateSession(ctx, ident, namespace, plugin)
+}
@@ -26,8 +26,15 @@ type TenantResponse struct {
 }
 
 // GetTenantByName returns the tenant by name.
-func (c *Client) GetTenantByName(ctx context.Context, name string) (TenantResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.host+fmt.Sprintf(pathTenantByName, name), nil)
+func (c *Client) GetTenantByName(ctx context.Context, name string) (_ TenantResponse, err error) {
+	op := Operation{Name: "client.GetTenantByName", Tenant: name}
+
+	ctx, span := c.startSpan(ctx, op)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	req, err := http.NewRequestWithContext(withOperation(ctx, op), http.MethodGet, c.host+fmt.Sprintf(pathTenantByName, name), nil)
 	if err != nil {
 		return TenantResponse{}, errors.Wrap(err, "client.GetTenantByName: http.NewRequest")
 	}
@@ -42,17 +49,14 @@ func (c *Client) GetTenantByName(ctx context.Context, name string) (TenantRespon
 	}()
 
 	if res.StatusCode != http.StatusOK {
-		return TenantResponse{}, fmt.Errorf(httpResponseCodeErrorFormat, "client.GetTenantByName", http.StatusOK, res.StatusCode)
+		return TenantResponse{}, newAPIError("client.GetTenantByName", http.StatusOK, res)
 	}
 
 	var t TenantResponse
 
-	dec := json.NewDecoder(res.Body)
-	dec.DisallowUnknownFields()
-
-	err = dec.Decode(&t)
+	err = c.decode(req.Context(), res, &t)
 	if err != nil {
-		return TenantResponse{}, errors.Wrap(err, "client.GetTenantByName: dec.Decode")
+		return TenantResponse{}, errors.Wrap(err, "client.GetTenantByName: c.decode")
 	}
 
 	return t, nil
@@ -66,7 +70,7 @@ type createTenantRequest struct {
 
 // CreateTenant creates a new tenant with the given name and description. Description is optional, it can be an
 // empty string.
-func (c *Client) CreateTenant(ctx context.Context, name, description string) (TenantResponse, error) {
+func (c *Client) CreateTenant(ctx context.Context, name, description string) (_ TenantResponse, err error) {
 	if name == emptyString {
 		return TenantResponse{}, errors.New("client.CreateTenant: tenant name cannot be empty")
 	}
@@ -82,7 +86,14 @@ func (c *Client) CreateTenant(ctx context.Context, name, description string) (Te
 		requestBody = bytes.NewReader(m)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.host+fmt.Sprintf(pathTenantByName, name), requestBody)
+	op := Operation{Name: "client.CreateTenant", Tenant: name}
+
+	ctx, span := c.startSpan(ctx, op)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	req, err := http.NewRequestWithContext(withOperation(ctx, op), http.MethodPost, c.host+fmt.Sprintf(pathTenantByName, name), requestBody)
 	if err != nil {
 		return TenantResponse{}, errors.Wrap(err, "client.CreateTenant: http.NewRequest for POST create tenant")
 	}
@@ -97,17 +108,14 @@ func (c *Client) CreateTenant(ctx context.Context, name, description string) (Te
 	}()
 
 	if res.StatusCode != http.StatusCreated {
-		return TenantResponse{}, fmt.Errorf(httpResponseCodeErrorFormat, "client.CreateTenant", http.StatusCreated, res.StatusCode)
+		return TenantResponse{}, newAPIError("client.CreateTenant", http.StatusCreated, res)
 	}
 
 	var t TenantResponse
 
-	dec := json.NewDecoder(res.Body)
-	dec.DisallowUnknownFields()
-
-	err = dec.Decode(&t)
+	err = c.decode(req.Context(), res, &t)
 	if err != nil {
-		return TenantResponse{}, errors.Wrap(err, "client.CreateTenant: dec.Decode")
+		return TenantResponse{}, errors.Wrap(err, "client.CreateTenant: c.decode")
 	}
 
 	return t, nil
@@ -119,8 +127,15 @@ type ListTenantResponse struct {
 }
 
 // ListTenants will list the tenants that the configured API key can access.
-func (c *Client) ListTenants(ctx context.Context) (ListTenantResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.host+pathTenant, nil)
+func (c *Client) ListTenants(ctx context.Context) (_ ListTenantResponse, err error) {
+	op := Operation{Name: "client.ListTenants"}
+
+	ctx, span := c.startSpan(ctx, op)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	req, err := http.NewRequestWithContext(withOperation(ctx, op), http.MethodGet, c.host+pathTenant, nil)
 	if err != nil {
 		return ListTenantResponse{}, errors.Wrap(err, "client.ListTenants: http.NewRequest")
 	}
@@ -135,17 +150,14 @@ func (c *Client) ListTenants(ctx context.Context) (ListTenantResponse, error) {
 	}()
 
 	if res.StatusCode != http.StatusOK {
-		return ListTenantResponse{}, fmt.Errorf(httpResponseCodeErrorFormat, "client.ListTenants", http.StatusOK, res.StatusCode)
+		return ListTenantResponse{}, newAPIError("client.ListTenants", http.StatusOK, res)
 	}
 
 	var t ListTenantResponse
 
-	dec := json.NewDecoder(res.Body)
-	dec.DisallowUnknownFields()
-
-	err = dec.Decode(&t)
+	err = c.decode(req.Context(), res, &t)
 	if err != nil {
-		return ListTenantResponse{}, errors.Wrap(err, "client.ListTenants: dec.Decode")
+		return ListTenantResponse{}, errors.Wrap(err, "client.ListTenants: c.decode")
 	}
 
 	return t, nil
@@ -158,7 +170,7 @@ type updateTenantRequest struct {
 }
 
 // UpdateTenantByName updates the description of the tenant identified by its name. A tenant's name cannot be changed.
-func (c *Client) UpdateTenantByName(ctx context.Context, name, description string) (TenantResponse, error) {
+func (c *Client) UpdateTenantByName(ctx context.Context, name, description string) (_ TenantResponse, err error) {
 	if name == emptyString {
 		return TenantResponse{}, errors.New("client.UpdateTenantByName: tenant name cannot be empty")
 	}
@@ -168,7 +180,14 @@ func (c *Client) UpdateTenantByName(ctx context.Context, name, description strin
 		return TenantResponse{}, errors.Wrap(err, "client.UpdateTenantByName: json marshal update tenant request")
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, c.host+fmt.Sprintf(pathTenantByName, name), bytes.NewReader(m))
+	op := Operation{Name: "client.UpdateTenantByName", Tenant: name}
+
+	ctx, span := c.startSpan(ctx, op)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	req, err := http.NewRequestWithContext(withOperation(ctx, op), http.MethodPatch, c.host+fmt.Sprintf(pathTenantByName, name), bytes.NewReader(m))
 	if err != nil {
 		return TenantResponse{}, errors.Wrap(err, "client.UpdateTenantByName: http.NewRequest for POST create tenant")
 	}
@@ -183,29 +202,33 @@ func (c *Client) UpdateTenantByName(ctx context.Context, name, description strin
 	}()
 
 	if res.StatusCode != http.StatusOK {
-		return TenantResponse{}, fmt.Errorf(httpResponseCodeErrorFormat, "client.UpdateTenantByName", http.StatusOK, res.StatusCode)
+		return TenantResponse{}, newAPIError("client.UpdateTenantByName", http.StatusOK, res)
 	}
 
 	var t TenantResponse
 
-	dec := json.NewDecoder(res.Body)
-	dec.DisallowUnknownFields()
-
-	err = dec.Decode(&t)
+	err = c.decode(req.Context(), res, &t)
 	if err != nil {
-		return TenantResponse{}, errors.Wrap(err, "client.UpdateTenantByName: dec.Decode")
+		return TenantResponse{}, errors.Wrap(err, "client.UpdateTenantByName: c.decode")
 	}
 
 	return t, nil
 }
 
 // DeleteTenantByName deletes the tenant identified by its name.
-func (c *Client) DeleteTenantByName(ctx context.Context, name string) error {
+func (c *Client) DeleteTenantByName(ctx context.Context, name string) (err error) {
 	if name == emptyString {
 		return errors.New("client.DeleteTenantByName: tenant name cannot be empty")
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, fmt.Sprintf(c.host+pathTenantByName, name), nil)
+	op := Operation{Name: "client.DeleteTenantByName", Tenant: name}
+
+	ctx, span := c.startSpan(ctx, op)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	req, err := http.NewRequestWithContext(withOperation(ctx, op), http.MethodDelete, fmt.Sprintf(c.host+pathTenantByName, name), nil)
 	if err != nil {
 		return errors.Wrap(err, "client.DeleteTenantByName: http.NewRequest")
 	}
@@ -220,7 +243,7 @@ func (c *Client) DeleteTenantByName(ctx context.Context, name string) error {
 	}()
 
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf(httpResponseCodeErrorFormat, "client.DeleteTenantByName", http.StatusOK, res.StatusCode)
+		return newAPIError("client.DeleteTenantByName", http.StatusOK, res)
 	}
 
 	return nil
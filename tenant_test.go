@@ -0,0 +1,86 @@
+package se2_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+)
+
+func TestTenantFromContext(t *testing.T) {
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		want   string
+		wantOK bool
+	}{
+		{name: "set", ctx: se2.WithTenant(context.Background(), "acme"), want: "acme", wantOK: true},
+		{name: "innermost wins", ctx: se2.WithTenant(se2.WithTenant(context.Background(), "acme"), "globex"), want: "globex", wantOK: true},
+		{name: "not set", ctx: context.Background()},
+		{name: "blank", ctx: se2.WithTenant(context.Background(), " ")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := se2.TenantFromContext(tt.ctx)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestClient_FromContext(t *testing.T) {
+	var paths []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+
+		switch r.URL.Path {
+		case "/environment/v1/tenant/acme/plugins":
+			_, _ = w.Write([]byte(`{"plugins":[]}`))
+		case "/environment/v1/tenant/acme/session":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"session"}`))
+		default:
+			_, _ = w.Write([]byte(`ok`))
+		}
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	ctx := se2.WithTenant(context.Background(), "acme")
+
+	out, err := client.ExecFromContext(ctx, []byte(`hi`), "default", "hello")
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`ok`), out)
+
+	_, err = client.GetPluginsFromContext(ctx)
+	require.NoError(t, err)
+
+	_, err = client.CreateSessionFromContext(ctx, "default", "hello")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"/name/acme/default/hello",
+		"/environment/v1/tenant/acme/plugins",
+		"/environment/v1/tenant/acme/session",
+	}, paths)
+
+	_, err = client.ExecFromContext(context.Background(), []byte(`hi`), "default", "hello")
+	assert.ErrorIs(t, err, se2.ErrNoTenant)
+
+	_, err = client.GetPluginsFromContext(context.Background())
+	assert.ErrorIs(t, err, se2.ErrNoTenant)
+
+	_, err = client.CreateSessionFromContext(context.Background(), "default", "hello")
+	assert.ErrorIs(t, err, se2.ErrNoTenant)
+
+	assert.Len(t, paths, 3, "nothing is sent without a tenant")
+}
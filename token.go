@@ -0,0 +1,164 @@
+package se2
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const defaultFileTokenCheckInterval = 10 * time.Second
+
+// TokenSource provides the environment access key for requests. It's consulted for every request, so implementations
+// should be cheap and safe to call from multiple goroutines.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc is an adapter to allow the use of ordinary functions, like a call to a secrets manager, as a
+// TokenSource.
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+// Token calls f(ctx).
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// staticTokenSource is a TokenSource that always returns the same access key.
+type staticTokenSource string
+
+// Token returns the access key.
+func (s staticTokenSource) Token(context.Context) (string, error) {
+	return string(s), nil
+}
+
+// StaticTokenSource returns a TokenSource that always returns the same access key. It returns ErrNoAccessKey if the
+// token is malformed.
+func StaticTokenSource(token string) (TokenSource, error) {
+	err := validateAccessKey(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return staticTokenSource(token), nil
+}
+
+// tokenSourceBox lets us swap a TokenSource of any concrete type atomically.
+type tokenSourceBox struct {
+	ts TokenSource
+}
+
+// SetTokenSource replaces the token source of the client. It's safe to call while requests are in flight; the ones
+// already sent keep the access key they were sent with.
+func (c *Client) SetTokenSource(ts TokenSource) {
+	c.token.Store(&tokenSourceBox{ts: ts})
+}
+
+// accessKey returns the current access key from the token source, making sure that it's well-formed.
+func (c *Client) accessKey(ctx context.Context) (string, error) {
+	box := c.token.Load()
+	if box == nil || box.ts == nil {
+		return emptyString, ErrNoAccessKey
+	}
+
+	token, err := box.ts.Token(ctx)
+	if err != nil {
+		return emptyString, errors.Wrap(err, "TokenSource.Token")
+	}
+
+	err = validateAccessKey(token)
+	if err != nil {
+		return emptyString, err
+	}
+
+	return token, nil
+}
+
+// fileTokenSource reads the access key from a file, and reads it again when the file changes.
+type fileTokenSource struct {
+	path     string
+	interval time.Duration
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+	size    int64
+	checked time.Time
+}
+
+// FileTokenSource returns a TokenSource that reads the access key from the file at path, like a mounted Kubernetes
+// secret. It checks the file for changes at most once per interval, 10 seconds if interval is zero, and reads the new
+// key when the file's modification time or size changed. Leading and trailing whitespace is ignored.
+//
+// If the key in a changed file is malformed, for example because the file is in the middle of being written, the last
+// good key keeps being used until the next check. It returns an error if the file can't be read, or the key in it is
+// malformed at the start.
+func FileTokenSource(path string, interval time.Duration) (TokenSource, error) {
+	if interval <= 0 {
+		interval = defaultFileTokenCheckInterval
+	}
+
+	fts := &fileTokenSource{
+		path:     path,
+		interval: interval,
+	}
+
+	err := fts.reload(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return fts, nil
+}
+
+// Token returns the access key in the file, reading it again if the file changed since the last check.
+func (f *fileTokenSource) Token(context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(f.checked) < f.interval {
+		return f.token, nil
+	}
+
+	// Keep serving the last good key if the file is unreadable or mid-write.
+	_ = f.reload(now)
+
+	return f.token, nil
+}
+
+// reload reads the file if it changed since the last read. It needs to be called with the lock held, or before the
+// token source is shared.
+func (f *fileTokenSource) reload(now time.Time) error {
+	f.checked = now
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return errors.Wrap(err, "os.Stat")
+	}
+
+	if f.token != emptyString && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return nil
+	}
+
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		return errors.Wrap(err, "os.ReadFile")
+	}
+
+	token := strings.TrimSpace(string(b))
+
+	err = validateAccessKey(token)
+	if err != nil {
+		return errors.Wrapf(err, "access key in %s", f.path)
+	}
+
+	f.token = token
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+
+	return nil
+}
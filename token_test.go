@@ -0,0 +1,70 @@
+package se2_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+)
+
+const rotatedAccessKey = "eyJrZXkiOjQwOCwic2VjcmV0Ijoicm90YXRlZFNlY3JldFZhbHVlRm9yVGVzdHNPbmx5MDEyMzQ1Njc4OWFiYz0ifQ=="
+
+func TestFileTokenSource(t *testing.T) {
+	var authorization string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+
+		_, _ = w.Write([]byte(`{"tenants":[]}`))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "token")
+
+	require.NoError(t, os.WriteFile(path, []byte(testAccessKey+"\n"), 0o600))
+
+	ts, err := se2.FileTokenSource(path, time.Nanosecond)
+	require.NoError(t, err)
+
+	client, err := se2.NewClientWithTokenSource(se2.ModeCustom, ts, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	_, err = client.ListTenants(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer "+testAccessKey, authorization)
+
+	// A half written file keeps the last good key in use.
+	require.NoError(t, os.WriteFile(path, []byte("eyJr"), 0o600))
+
+	_, err = client.ListTenants(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer "+testAccessKey, authorization)
+
+	require.NoError(t, os.WriteFile(path, []byte(rotatedAccessKey), 0o600))
+
+	_, err = client.ListTenants(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer "+rotatedAccessKey, authorization)
+
+	// Swapping the token source takes effect on the next request.
+	client.SetTokenSource(se2.TokenSourceFunc(func(context.Context) (string, error) {
+		return "not an access key", nil
+	}))
+
+	_, err = client.ListTenants(context.Background())
+	assert.ErrorIs(t, err, se2.ErrNoAccessKey)
+
+	_, err = se2.FileTokenSource(filepath.Join(t.TempDir(), "missing"), 0)
+	assert.Error(t, err)
+
+	_, err = se2.StaticTokenSource("short")
+	assert.ErrorIs(t, err, se2.ErrNoAccessKey)
+}
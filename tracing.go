@@ -0,0 +1,167 @@
+package se2
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/suborbital/se2-go"
+
+// WithTracerProvider turns on OpenTelemetry tracing. Every call to a client method opens a span named after the method,
+// like "se2.Exec", with the tenant, namespace, plugin, template, and ref it works on as attributes. It covers the whole
+// call, including the answers of the exec cache and of open circuit breakers, which are marked with the se2.cache_hit
+// and se2.circuit_open attributes. Every HTTP request the call sends, including retries and hedged requests, gets a
+// child span named after its method, like "HTTP POST", with the status code of the response, which lasts until the
+// response body is closed. Waiting for a retry is recorded as an event on the span of the call. Methods that only hand
+// off to another one, like ExecFQMN and ExecFromContext, get the span of that one.
+//
+// The span context of the request is injected into the outgoing requests to both the admin and the exec host with the
+// W3C Trace Context propagator, unless another one is set with WithPropagator.
+func WithTracerProvider(tp trace.TracerProvider) ClientOption {
+	return func(c *Client) {
+		c.tracer = tp.Tracer(tracerName)
+
+		if c.propagator == nil {
+			c.propagator = propagation.TraceContext{}
+		}
+	}
+}
+
+// WithPropagator sets the propagator used to inject the trace context into outgoing requests. It works without
+// WithTracerProvider too, in which case the span in the context passed to the client method is propagated.
+func WithPropagator(propagator propagation.TextMapPropagator) ClientOption {
+	return func(c *Client) {
+		c.propagator = propagator
+	}
+}
+
+// noopSpan is the span of calls when tracing is off.
+var noopSpan = trace.SpanFromContext(context.Background())
+
+// startSpan opens the span of a call to a client method, which the spans of its requests are children of. If tracing
+// is off, it returns ctx unchanged, and a span that does nothing.
+func (c *Client) startSpan(ctx context.Context, op Operation) (context.Context, trace.Span) {
+	if c.tracer == nil {
+		return ctx, noopSpan
+	}
+
+	return c.tracer.Start(ctx, spanName(op), trace.WithAttributes(operationAttributes(op)...))
+}
+
+// endSpan ends the span of a call to a client method, marking it as failed if the call returned an error.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// startAttempt opens the span of an attempt at sending the request if tracing is on, and injects the trace context into
+// the headers of the request.
+func (c *Client) startAttempt(req *http.Request, attempt int) (*http.Request, trace.Span) {
+	span := noopSpan
+
+	if c.tracer != nil {
+		var ctx context.Context
+
+		ctx, span = c.tracer.Start(req.Context(), "HTTP "+req.Method,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("http.request.method", req.Method),
+				attribute.String("server.address", req.URL.Host),
+				attribute.String("url.path", req.URL.Path),
+			),
+		)
+
+		if attempt > 1 {
+			span.SetAttributes(attribute.Int("http.resend_count", attempt-1))
+		}
+
+		req = req.WithContext(ctx)
+	}
+
+	if c.propagator != nil {
+		c.propagator.Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+	}
+
+	return req, span
+}
+
+// endAttempt records the outcome of an attempt on its span. If there is a response, the span ends when its body is
+// closed, so it covers reading the response, otherwise it ends right away.
+func endAttempt(span trace.Span, res *http.Response, err error) {
+	if err != nil {
+		endSpan(span, err)
+
+		return
+	}
+
+	span.SetAttributes(attribute.Int("http.response.status_code", res.StatusCode))
+
+	if res.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(res.StatusCode))
+	}
+
+	res.Body = &spanBody{ReadCloser: res.Body, span: span}
+}
+
+// spanName turns the name of the operation, "client.Exec", into the name of its span, "se2.Exec".
+func spanName(op Operation) string {
+	if op.Name == emptyString {
+		return "se2.request"
+	}
+
+	return "se2." + strings.TrimPrefix(op.Name, "client.")
+}
+
+// operationAttributes returns the span attributes of the operation, leaving out the fields that are empty.
+func operationAttributes(op Operation) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, 6)
+
+	for _, kv := range []struct {
+		key   string
+		value string
+	}{
+		{"se2.operation", op.Name},
+		{"se2.tenant", op.Tenant},
+		{"se2.namespace", op.Namespace},
+		{"se2.plugin", op.Plugin},
+		{"se2.template", op.Template},
+		{"se2.ref", op.Ref},
+	} {
+		if kv.value != emptyString {
+			attrs = append(attrs, attribute.String(kv.key, kv.value))
+		}
+	}
+
+	return attrs
+}
+
+// spanBody ends a span when the response body is closed, so the span covers reading the response.
+type spanBody struct {
+	io.ReadCloser
+
+	span trace.Span
+	once sync.Once
+}
+
+// Close closes the response body and ends the span.
+func (b *spanBody) Close() error {
+	err := b.ReadCloser.Close()
+
+	b.once.Do(func() {
+		b.span.End()
+	})
+
+	return err //nolint:wrapcheck
+}
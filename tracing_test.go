@@ -0,0 +1,223 @@
+package se2_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/suborbital/se2-go"
+)
+
+func TestWithTracerProvider(t *testing.T) {
+	var traceparents []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+
+		if r.URL.Path == "/name/acme/default/missing" {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"plugins":[]}`))
+	}))
+	defer srv.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey,
+		se2.WithHosts(srv.URL, srv.URL),
+		se2.WithTracerProvider(tp),
+	)
+	require.NoError(t, err)
+
+	_, err = client.GetPlugins(context.Background(), "acme")
+	require.NoError(t, err)
+
+	_, err = client.Exec(context.Background(), []byte(`hi`), "acme", "default", "missing")
+	require.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 4)
+	require.Len(t, traceparents, 2)
+
+	// The span of a request ends when its body is closed, before the span of the call does.
+	assert.Equal(t, "HTTP GET", spans[0].Name())
+	assert.Equal(t, "se2.GetPlugins", spans[1].Name())
+	assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Contains(t, spans[1].Attributes(), attribute.String("se2.tenant", "acme"))
+	assert.Contains(t, spans[0].Attributes(), attribute.Int("http.response.status_code", http.StatusOK))
+	assert.Contains(t, traceparents[0], spans[0].SpanContext().SpanID().String())
+
+	assert.Equal(t, "HTTP POST", spans[2].Name())
+	assert.Equal(t, "se2.Exec", spans[3].Name())
+	assert.Equal(t, spans[3].SpanContext().SpanID(), spans[2].Parent().SpanID())
+	assert.Contains(t, spans[3].Attributes(), attribute.String("se2.plugin", "missing"))
+	assert.Equal(t, codes.Error, spans[2].Status().Code)
+	assert.Equal(t, codes.Error, spans[3].Status().Code)
+	assert.Contains(t, traceparents[1], spans[2].SpanContext().SpanID().String())
+}
+
+func TestWithTracerProvider_Calls(t *testing.T) {
+	var requests int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+
+		switch r.URL.Path {
+		case "/name/acme/default/flaky":
+			if n == 1 {
+				w.WriteHeader(http.StatusTooManyRequests)
+
+				return
+			}
+		case "/name/acme/default/slow":
+			// The first request is slow, so the hedged one wins.
+			if n == 1 {
+				time.Sleep(50 * time.Millisecond)
+			}
+		case "/name/acme/default/down":
+			w.WriteHeader(http.StatusBadGateway)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`ok`))
+	}))
+	defer srv.Close()
+
+	flaky := se2.ExecTarget{Ident: "acme", Namespace: "default", Plugin: "flaky"}
+	slow := se2.ExecTarget{Ident: "acme", Namespace: "default", Plugin: "slow"}
+	down := se2.ExecTarget{Ident: "acme", Namespace: "default", Plugin: "down"}
+
+	tests := []struct {
+		name      string
+		target    se2.ExecTarget
+		opts      []se2.ClientOption
+		calls     int
+		wantHTTP  int
+		wantAttrs []attribute.KeyValue
+		wantEvent string
+	}{
+		{
+			name:      "retries",
+			target:    flaky,
+			opts:      []se2.ClientOption{se2.WithRetryPolicy(se2.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond})},
+			calls:     1,
+			wantHTTP:  2,
+			wantEvent: "retry",
+		},
+		{
+			name:     "hedging",
+			target:   slow,
+			opts:     []se2.ClientOption{se2.WithHedging(se2.HedgePolicy{Plugins: []se2.ExecTarget{slow}, Delay: 5 * time.Millisecond})},
+			calls:    1,
+			wantHTTP: 2,
+		},
+		{
+			name:      "cache hit",
+			target:    pure,
+			opts:      []se2.ClientOption{se2.WithExecCache(se2.ExecCacheOptions{Plugins: []se2.ExecTarget{pure}, TTL: time.Minute})},
+			calls:     2,
+			wantHTTP:  1,
+			wantAttrs: []attribute.KeyValue{attribute.Bool("se2.cache_hit", true)},
+		},
+		{
+			name:      "open circuit",
+			target:    down,
+			opts:      []se2.ClientOption{se2.WithCircuitBreaker(se2.BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Hour})},
+			calls:     2,
+			wantHTTP:  1,
+			wantAttrs: []attribute.KeyValue{attribute.Bool("se2.circuit_open", true)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&requests, 0)
+
+			recorder := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+			opts := append([]se2.ClientOption{se2.WithHosts(srv.URL, srv.URL), se2.WithTracerProvider(tp)}, tt.opts...)
+
+			client, err := se2.NewClient(se2.ModeCustom, testAccessKey, opts...)
+			require.NoError(t, err)
+
+			for i := 0; i < tt.calls; i++ {
+				_, _ = client.Exec(context.Background(), []byte(`hi`), tt.target.Ident, tt.target.Namespace, tt.target.Plugin)
+			}
+
+			// The losing hedged request ends in the background.
+			time.Sleep(60 * time.Millisecond)
+
+			var (
+				calls        []sdktrace.ReadOnlySpan
+				requestSpans []sdktrace.ReadOnlySpan
+			)
+
+			for _, span := range recorder.Ended() {
+				if span.Name() == "se2.Exec" {
+					calls = append(calls, span)
+				} else {
+					requestSpans = append(requestSpans, span)
+				}
+			}
+
+			require.Len(t, calls, tt.calls, "every call has a span")
+			assert.Len(t, requestSpans, tt.wantHTTP)
+
+			for _, span := range requestSpans {
+				assert.Equal(t, calls[0].SpanContext().SpanID(), span.Parent().SpanID(), "requests are children of the call")
+			}
+
+			last := calls[len(calls)-1]
+
+			for _, attr := range tt.wantAttrs {
+				assert.Contains(t, last.Attributes(), attr)
+			}
+
+			if tt.wantEvent != "" {
+				require.Len(t, last.Events(), 1)
+				assert.Equal(t, tt.wantEvent, last.Events()[0].Name)
+				assert.Contains(t, requestSpans[1].Attributes(), attribute.Int("http.resend_count", 1))
+			}
+		})
+	}
+}
+
+func TestWithTracerProvider_WaitForPluginReady(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"plugins":[{"name":"hello","namespace":"default","lang":"js","ref":"abc","apiVersion":"","fqmn":"","uri":""}]}`))
+	}))
+	defer srv.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL), se2.WithTracerProvider(tp))
+	require.NoError(t, err)
+
+	err = client.WaitForPluginReady(context.Background(), "acme", "default", "hello", "abc", se2.WaitOptions{})
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	assert.Equal(t, "se2.GetPlugins", spans[1].Name())
+	assert.Equal(t, "se2.WaitForPluginReady", spans[2].Name())
+	assert.Equal(t, spans[2].SpanContext().SpanID(), spans[1].Parent().SpanID())
+	assert.Contains(t, spans[2].Attributes(), attribute.String("se2.ref", "abc"))
+}
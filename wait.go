@@ -0,0 +1,233 @@
+package se2
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultWaitTimeout        = 2 * time.Minute
+	defaultWaitInitialBackoff = 500 * time.Millisecond
+	defaultWaitMaxBackoff     = 5 * time.Second
+	defaultWaitMultiplier     = 1.5
+	defaultWaitJitter         = 0.2
+)
+
+// ErrPluginNotReady is matched by the error of WaitForPluginReady when the plugin did not become ready in time.
+var ErrPluginNotReady = errors.New("plugin is not ready")
+
+// WaitOptions configures WaitForPluginReady. The zero value is ready to use.
+type WaitOptions struct {
+	// Timeout is how long to wait for the plugin in total. Defaults to 2 minutes. The deadline of the context is
+	// honored too, whichever comes first.
+	Timeout time.Duration
+
+	// InitialBackoff is the wait before the second check, and MaxBackoff caps the wait between any two checks. Waits
+	// grow by Multiplier after each check. They default to 500ms, 5 seconds, and 1.5.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// Probe also runs the plugin on the edge once GetPlugins lists the ref, with ProbePayload as input, and only
+	// considers it ready once that succeeds. Only turn this on for plugins that are safe to run with the probe payload.
+	Probe        bool
+	ProbePayload []byte
+}
+
+// backoff returns the retry policy that spaces out the checks.
+func (o WaitOptions) backoff() RetryPolicy {
+	p := RetryPolicy{
+		InitialBackoff: o.InitialBackoff,
+		MaxBackoff:     o.MaxBackoff,
+		Multiplier:     o.Multiplier,
+		Jitter:         defaultWaitJitter,
+	}
+
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = defaultWaitInitialBackoff
+	}
+
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaultWaitMaxBackoff
+	}
+
+	if p.Multiplier <= 0 {
+		p.Multiplier = defaultWaitMultiplier
+	}
+
+	return p
+}
+
+// PluginNotReadyError is returned by WaitForPluginReady when the plugin did not become ready before the timeout, or
+// before the context was done. It matches ErrPluginNotReady, the error of the context, and the error of the last check.
+type PluginNotReadyError struct {
+	Tenant    string
+	Namespace string
+	Plugin    string
+	Ref       string
+
+	// LiveRef is the ref GetPlugins listed for the plugin at the last check, empty if the plugin was not listed.
+	LiveRef string
+
+	// Checks is how many times the plugin was checked, and Waited how long it was waited for.
+	Checks int
+	Waited time.Duration
+
+	// LastErr is the error of the last check, if it failed, and ctxErr the reason the wait ended.
+	LastErr error
+	ctxErr  error
+}
+
+// Error implements the error interface.
+func (e *PluginNotReadyError) Error() string {
+	msg := fmt.Sprintf("plugin %s/%s/%s", e.Tenant, e.Namespace, e.Plugin)
+	if e.Ref != emptyString {
+		msg += "@" + e.Ref
+	}
+
+	msg += fmt.Sprintf(" is not ready after %s and %d checks", e.Waited.Round(time.Millisecond), e.Checks)
+
+	switch {
+	case e.LastErr != nil:
+		msg += ": " + e.LastErr.Error()
+	case e.LiveRef != emptyString:
+		msg += ": live ref is " + e.LiveRef
+	default:
+		msg += ": not listed by GetPlugins"
+	}
+
+	return msg
+}
+
+// Is makes the error match ErrPluginNotReady.
+func (e *PluginNotReadyError) Is(target error) bool {
+	return target == ErrPluginNotReady
+}
+
+// Unwrap returns the error of the context and the error of the last check.
+func (e *PluginNotReadyError) Unwrap() []error {
+	errs := make([]error, 0, 2)
+
+	for _, err := range []error{e.ctxErr, e.LastErr} {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// WaitForPluginReady waits until the plugin is serving the promoted ref, for example the one returned by
+// PromotePluginDraft. It polls GetPlugins until the plugin is listed with the ref, and with Probe set, runs it with
+// ExecRef until that succeeds too. If ref is empty, any version of the plugin will do, and the probe runs it by name.
+//
+// Checks are spaced out with exponential backoff. If the plugin is not ready before the timeout, it returns a
+// *PluginNotReadyError. Errors that waiting won't fix, like an invalid access key, are returned right away.
+func (c *Client) WaitForPluginReady(ctx context.Context, tenant, namespace, plugin, ref string, opts WaitOptions) (err error) {
+	ctx, span := c.startSpan(ctx, Operation{Name: "client.WaitForPluginReady", Tenant: tenant, Namespace: namespace, Plugin: plugin, Ref: ref})
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultWaitTimeout
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := opts.backoff()
+	start := time.Now()
+
+	notReady := &PluginNotReadyError{
+		Tenant:    tenant,
+		Namespace: namespace,
+		Plugin:    plugin,
+		Ref:       ref,
+	}
+
+	for {
+		notReady.Checks++
+
+		ready, err := c.checkPluginReady(waitCtx, notReady, opts)
+		if err != nil {
+			return errors.Wrap(err, "client.WaitForPluginReady")
+		}
+
+		if ready {
+			return nil
+		}
+
+		timer := time.NewTimer(backoff.backoff(notReady.Checks))
+
+		select {
+		case <-timer.C:
+		case <-waitCtx.Done():
+			timer.Stop()
+
+			notReady.Waited = time.Since(start)
+			notReady.ctxErr = waitCtx.Err()
+
+			return notReady
+		}
+	}
+}
+
+// checkPluginReady checks once whether the plugin in the error is ready, and records the outcome on it. It returns an
+// error only if it's not worth checking again.
+func (c *Client) checkPluginReady(ctx context.Context, state *PluginNotReadyError, opts WaitOptions) (bool, error) {
+	plugins, err := c.GetPlugins(ctx, state.Tenant)
+	if err != nil {
+		return false, state.checkFailed(ctx, err)
+	}
+
+	state.LastErr = nil
+	state.LiveRef = emptyString
+
+	listed := false
+
+	for _, p := range plugins.Plugins {
+		if p.Name != state.Plugin || p.Namespace != state.Namespace {
+			continue
+		}
+
+		state.LiveRef = p.Ref
+		listed = state.Ref == emptyString || p.Ref == state.Ref
+
+		break
+	}
+
+	if !listed || !opts.Probe {
+		return listed, nil
+	}
+
+	if state.Ref == emptyString {
+		_, err = c.Exec(ctx, opts.ProbePayload, state.Tenant, state.Namespace, state.Plugin)
+	} else {
+		_, err = c.ExecRef(ctx, opts.ProbePayload, state.Ref)
+	}
+
+	if err != nil {
+		return false, state.checkFailed(ctx, err)
+	}
+
+	return true, nil
+}
+
+// checkFailed keeps the error of a failed check, and returns it if it can't be fixed by waiting. A check cut short
+// because the wait is over is not recorded, so the error describes the last check that completed.
+func (e *PluginNotReadyError) checkFailed(ctx context.Context, err error) error {
+	if errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrForbidden) {
+		return err
+	}
+
+	if ctx.Err() == nil {
+		e.LastErr = err
+	}
+
+	return nil
+}
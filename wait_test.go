@@ -0,0 +1,110 @@
+package se2_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/suborbital/se2-go"
+)
+
+// propagatingServer lists the plugin with the old ref for the first few GetPlugins calls, and the new one after that.
+// The edge only serves the new ref from the first probe that comes after that, to model a lagging edge.
+func propagatingServer(t *testing.T, listCalls, probeCalls *int32, propagateAfter int32) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/environment/v1/tenant/acme/plugins":
+			ref := "old"
+			if atomic.AddInt32(listCalls, 1) > propagateAfter {
+				ref = "new"
+			}
+
+			_, _ = fmt.Fprintf(w, `{"plugins":[{"name":"hello","namespace":"default","lang":"js","ref":%q,"apiVersion":"","fqmn":"","uri":""}]}`, ref)
+		case "/ref/new":
+			if atomic.AddInt32(probeCalls, 1) == 1 {
+				w.WriteHeader(http.StatusNotFound)
+
+				return
+			}
+
+			_, _ = w.Write([]byte(`ok`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestClient_WaitForPluginReady(t *testing.T) {
+	var listCalls, probeCalls int32
+
+	srv := propagatingServer(t, &listCalls, &probeCalls, 2)
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	err = client.WaitForPluginReady(context.Background(), "acme", "default", "hello", "new", se2.WaitOptions{
+		Timeout:        5 * time.Second,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Probe:          true,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(4), atomic.LoadInt32(&listCalls))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&probeCalls))
+}
+
+func TestClient_WaitForPluginReady_Timeout(t *testing.T) {
+	var listCalls, probeCalls int32
+
+	srv := propagatingServer(t, &listCalls, &probeCalls, 1<<30)
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	err = client.WaitForPluginReady(context.Background(), "acme", "default", "hello", "new", se2.WaitOptions{
+		Timeout:        50 * time.Millisecond,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+	})
+	require.Error(t, err)
+
+	assert.ErrorIs(t, err, se2.ErrPluginNotReady)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	var notReady *se2.PluginNotReadyError
+
+	require.ErrorAs(t, err, &notReady)
+	assert.Equal(t, "old", notReady.LiveRef)
+	assert.Positive(t, notReady.Checks)
+	assert.Contains(t, err.Error(), "acme/default/hello@new is not ready")
+	assert.Zero(t, atomic.LoadInt32(&probeCalls))
+}
+
+func TestClient_WaitForPluginReady_Unauthorized(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	client, err := se2.NewClient(se2.ModeCustom, testAccessKey, se2.WithHosts(srv.URL, srv.URL))
+	require.NoError(t, err)
+
+	err = client.WaitForPluginReady(context.Background(), "acme", "default", "hello", "new", se2.WaitOptions{})
+
+	assert.ErrorIs(t, err, se2.ErrUnauthorized)
+	assert.NotErrorIs(t, err, se2.ErrPluginNotReady)
+}